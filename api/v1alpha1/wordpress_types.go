@@ -17,7 +17,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -28,24 +33,909 @@ type WordpressSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// SqlRootPassword can be used to set the root password for the MySQL database
-	SqlRootPassword string `json:"sqlRootPassword"`
+	// SqlRootPassword can be used to set the root password for the MySQL database.
+	// It only seeds the operator managed Secret when that Secret is first created.
+	//
+	// Deprecated: use CredentialsSecretRef, which takes precedence over this field.
+	// +optional
+	SqlRootPassword string `json:"sqlRootPassword,omitempty"`
+
+	// CredentialsSecretRef points to a user managed Secret in the namespace of the
+	// instance holding the database credentials. The operator reads it but never
+	// changes it. When it is not set the root password is taken from
+	// SqlRootPassword, and failing that generated.
+	// +optional
+	CredentialsSecretRef *CredentialsSecretReference `json:"credentialsSecretRef,omitempty"`
+
+	// RotationInterval is the time between two rotations of the passwords the
+	// operator generated, for example 2160h for 90 days. Passwords held in
+	// CredentialsSecretRef are left to whoever manages that Secret. Without an
+	// interval passwords are only rotated on request, see
+	// RotateCredentialsAnnotation.
+	// +optional
+	RotationInterval *metav1.Duration `json:"rotationInterval,omitempty"`
 
 	// Replicas is the number of Wordpress replicas
 	Replicas *int32 `json:"replicas,omitempty"` // you have to add this line in order create replicas for wordpress
 
+	// Autoscaling hands the number of WordPress replicas over to a
+	// HorizontalPodAutoscaler. Replicas is ignored while it is set.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
 	//MysqlReplicas is the number of Mysql replicas
 	MysqlReplicas *int32 `json:"mysqlReplicas,omitempty"` //you have to add this line in order to create replicas for mysql
+
+	// Image selects the WordPress image
+	// +optional
+	Image *WordpressImage `json:"image,omitempty"`
+
+	// DatabaseImage is the MySQL server image, it must be MySQL 8.0.22 or later.
+	// Instances installed before it existed ran the unpinned mysql image and
+	// keep it, MySQL refuses to start an older version on their data. Pin it
+	// to the version the site runs.
+	// +optional
+	DatabaseImage string `json:"databaseImage,omitempty"`
+
+	// BackupImage is the image backup jobs run mysqldump from, it needs bash and
+	// gzip too. Defaults to the database image so that client and server
+	// versions match.
+	// +optional
+	BackupImage string `json:"backupImage,omitempty"`
+
+	// ImagePullSecrets are used to pull every image of the instance
+	// +optional
+	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// ImagePullPolicy applies to every container of the instance
+	// +kubebuilder:validation:Enum=Always;IfNotPresent;Never
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Storage configures the volumes of the instance
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Content decides where the files of the site live
+	// +optional
+	Content *ContentSpec `json:"content,omitempty"`
+
+	// Service configures the Service WordPress is reached through
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// Ingress exposes the site through an Ingress
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// Gateway exposes the site through a Gateway API HTTPRoute
+	// +optional
+	Gateway *GatewaySpec `json:"gateway,omitempty"`
+
+	// Pods sets the resources and the placement of the pods of each tier
+	// +optional
+	Pods *PodsSpec `json:"pods,omitempty"`
+
+	// ServiceAccounts configures the ServiceAccount the operator creates
+	// for each tier
+	// +optional
+	ServiceAccounts *ServiceAccountsSpec `json:"serviceAccounts,omitempty"`
+
+	// NetworkPolicies restricts the traffic of the instance
+	// +optional
+	NetworkPolicies *NetworkPoliciesSpec `json:"networkPolicies,omitempty"`
+
+	// DisruptionBudgets overrides the PodDisruptionBudgets of each tier
+	// +optional
+	DisruptionBudgets *DisruptionBudgetsSpec `json:"disruptionBudgets,omitempty"`
+
+	// Probes overrides the health checks of the WordPress and MySQL
+	// containers
+	// +optional
+	Probes *ProbesSpec `json:"probes,omitempty"`
+
+	// Backup configures the scheduled database backups
+	// +optional
+	Backup *BackupSpec `json:"backup,omitempty"`
+
+	// DataSource seeds the volumes of a new instance. It only applies when
+	// the volumes are created and cannot be changed afterwards.
+	// +optional
+	DataSource *WordpressDataSource `json:"dataSource,omitempty"`
+
+	// DeletionPolicy decides what happens to the data of the instance when
+	// the CR is deleted. Retain keeps the volumes and the generated credentials
+	// Secrets, Snapshot takes a final backup first and waits for it to
+	// succeed, Delete removes everything. Defaults to Retain.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy is what happens to the data of an instance when it is deleted
+// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete removes the volumes and the generated Secrets
+	// together with the instance
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain leaves the volumes and the generated Secrets
+	// behind, marked with RetainedFromAnnotation. A new instance of the same
+	// name picks them up again.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshot takes a final WordpressBackup, with the method
+	// of the scheduled backups, and only lets the instance go once it has
+	// succeeded. The backup outlives the instance, a backup volume holding
+	// it is retained. If the backup fails the instance stays until the
+	// policy is changed.
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// WordpressDataSource is what a new instance is cloned from
+type WordpressDataSource struct {
+	// BackupRef is a succeeded VolumeSnapshot backup, in the namespace of the
+	// instance, whose snapshots the volumes are created from. The database
+	// keeps the users of the snapshot, so the root password is taken from
+	// the backup too, unless CredentialsSecretRef is set, in which case it
+	// must hold the root password the backed up instance had.
+	BackupRef corev1.LocalObjectReference `json:"backupRef"`
+}
+
+// CredentialsSecretReference selects the database credentials in a Secret
+type CredentialsSecretReference struct {
+	// Name of the Secret
+	Name string `json:"name"`
+
+	// RootPasswordKey is the key of the MySQL root password
+	// +optional
+	RootPasswordKey string `json:"rootPasswordKey,omitempty"`
+
+	// UsernameKey is the key of the user WordPress connects as. When the Secret
+	// does not have it the operator generates the user into a Secret of its own.
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// PasswordKey is the key of the password of the WordPress user
+	// +optional
+	PasswordKey string `json:"passwordKey,omitempty"`
+}
+
+// Default keys of the credentials Secret
+const (
+	DefaultRootPasswordKey = "root-password"
+	DefaultUsernameKey     = "username"
+	DefaultPasswordKey     = "password"
+)
+
+// StorageSpec configures each volume of the instance
+type StorageSpec struct {
+	// Wordpress is the volume holding /var/www/html
+	// +optional
+	Wordpress VolumeSpec `json:"wordpress,omitempty"`
+
+	// Mysql is the volume template of each MySQL pod
+	// +optional
+	Mysql VolumeSpec `json:"mysql,omitempty"`
+
+	// Backup is the volume backups are written to
+	// +optional
+	Backup VolumeSpec `json:"backup,omitempty"`
+}
+
+// VolumeSpec configures a PersistentVolumeClaim created by the operator
+type VolumeSpec struct {
+	// Size is the requested capacity. It can be increased later on, which
+	// expands the claim if its storage class allows it, but never decreased.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName is the storage class of the claim. It cannot be
+	// changed once the claim exists.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes of the claim, ReadWriteOnce when empty. They cannot be
+	// changed once the claim exists.
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// VolumeMode of the claim. The volumes are mounted as directories, so
+	// only Filesystem is supported. It cannot be changed once the claim exists.
+	// +optional
+	VolumeMode *corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+}
+
+// ContentMode is where the files of the site live
+// +kubebuilder:validation:Enum=Volume;Shared;Stateless
+type ContentMode string
+
+const (
+	// ContentVolume keeps /var/www/html on the volume configured by
+	// spec.storage.wordpress, ReadWriteOnce unless it says otherwise. Every
+	// replica has to run on the node the volume is attached to.
+	ContentVolume ContentMode = "Volume"
+	// ContentShared keeps /var/www/html on a ReadWriteMany volume, so that
+	// replicas can run on any node. The storage class has to support it,
+	// NFS, CephFS or EFS for example.
+	ContentShared ContentMode = "Shared"
+	// ContentStateless keeps no volume. Each pod unpacks WordPress from its
+	// image, which has the plugins and themes of the site baked in, and
+	// changing files from the dashboard is turned off. Uploads go to object
+	// storage, see ContentSpec.Uploads. Backups only hold the database.
+	ContentStateless ContentMode = "Stateless"
+)
+
+// ContentSpec decides where the files of the site live
+type ContentSpec struct {
+	// Mode is Volume, Shared or Stateless. Defaults to Volume. It cannot be
+	// changed once the instance exists.
+	// +optional
+	Mode ContentMode `json:"mode,omitempty"`
+
+	// Uploads is the object storage a Stateless instance keeps uploaded media
+	// in. Required in Stateless mode.
+	// +optional
+	Uploads *UploadsSpec `json:"uploads,omitempty"`
+}
+
+// UploadsSpec is the bucket uploaded media are kept in. WordPress does not
+// speak S3 on its own, the image needs an uploads plugin such as S3-Uploads.
+// The settings are passed in the S3_UPLOADS_BUCKET, S3_UPLOADS_REGION,
+// S3_UPLOADS_KEY and S3_UPLOADS_SECRET constants that plugin reads, and
+// S3_UPLOADS_ENDPOINT for S3-compatible services.
+type UploadsSpec struct {
+	// Bucket uploads are stored in
+	Bucket string `json:"bucket"`
+
+	// Prefix of the object keys. Defaults to <namespace>/<name>/uploads of
+	// the instance, so several sites can share a bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Endpoint of an S3-compatible service. Defaults to AWS S3.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// CredentialsSecretRef is a Secret in the namespace of the instance with
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY keys
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+}
+
+// AutoscalingSpec configures the HorizontalPodAutoscaler of the WordPress
+// Deployment. Utilisation is relative to the requests of the WordPress
+// container, see PodsSpec. Without any target the CPU is kept at 80%.
+type AutoscalingSpec struct {
+	// MinReplicas is the lowest number of replicas. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the highest number of replicas
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetCPUUtilizationPercentage is the average CPU utilisation to keep
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// TargetMemoryUtilizationPercentage is the average memory utilisation
+	// to keep
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+
+	// Metrics are added to the utilisation targets, for example the
+	// requests per second reported by an ingress controller
+	// +optional
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
+
+	// Behavior tunes how fast the replicas follow the metrics
+	// +optional
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// PodsSpec configures the pods of each tier
+type PodsSpec struct {
+	// Wordpress applies to the WordPress pods. Unless an affinity is set
+	// they prefer to run on different nodes.
+	// +optional
+	Wordpress *PodTemplateOverrides `json:"wordpress,omitempty"`
+
+	// Mysql applies to the MySQL pods. Unless an affinity is set they
+	// prefer to run on different nodes.
+	// +optional
+	Mysql *PodTemplateOverrides `json:"mysql,omitempty"`
+
+	// Jobs applies to the Jobs of the instance: backups, restores, the
+	// database user setup and credential rotations
+	// +optional
+	Jobs *PodTemplateOverrides `json:"jobs,omitempty"`
+}
+
+// PodTemplateOverrides are merged into the pod templates the operator builds
+type PodTemplateOverrides struct {
+	// Resources of the main container of the pods. They replace the
+	// default requests of the WordPress and MySQL containers as a whole.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// SidecarResources of the other containers of the pods, nginx in front
+	// of the fpm variant and the replication sidecar of MySQL. They replace
+	// the default requests of the sidecars as a whole.
+	// +optional
+	SidecarResources *corev1.ResourceRequirements `json:"sidecarResources,omitempty"`
+
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity replaces the default anti-affinity
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// SecurityContext replaces the default security context of the pods as
+	// a whole. The defaults meet the restricted Pod Security Standard: the
+	// pods run as a non-root user, www-data for WordPress and the Jobs and
+	// mysql for MySQL, with the RuntimeDefault seccomp profile. WordPress
+	// pods set the net.ipv4.ip_unprivileged_port_start sysctl to serve on
+	// port 80, a replacement has to keep it.
+	// +optional
+	SecurityContext *corev1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext replaces the default security context of
+	// every container of the pods. By default no capabilities are kept,
+	// privilege escalation is turned off and the WordPress and MySQL
+	// containers have a read-only root file system.
+	// +optional
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+}
+
+// ServiceAccountsSpec configures the ServiceAccount of each tier. None of
+// the pods of the instance talks to the Kubernetes API, no Role is bound to
+// the ServiceAccounts.
+type ServiceAccountsSpec struct {
+	// +optional
+	Wordpress *ServiceAccountSpec `json:"wordpress,omitempty"`
+
+	// +optional
+	Mysql *ServiceAccountSpec `json:"mysql,omitempty"`
+
+	// Jobs is the ServiceAccount of the backups, restores, the database
+	// user setup and credential rotations, for example to let backups
+	// upload to S3 with the workload identity of the cloud provider
+	// +optional
+	Jobs *ServiceAccountSpec `json:"jobs,omitempty"`
+}
+
+// ServiceAccountSpec configures the ServiceAccount of a tier
+type ServiceAccountSpec struct {
+	// Annotations added to the ServiceAccount, for example
+	// eks.amazonaws.com/role-arn or iam.gke.io/gcp-service-account
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// AutomountServiceAccountToken mounts an API token into the pods.
+	// Defaults to false.
+	// +optional
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+}
+
+// NetworkPoliciesSpec configures the NetworkPolicies of the instance
+type NetworkPoliciesSpec struct {
+	// Database restricts connections to MySQL to WordPress, the Jobs of
+	// the instance and the other MySQL pods. Defaults to true.
+	// +optional
+	Database *bool `json:"database,omitempty"`
+
+	// WordpressEgress, when set, only lets WordPress reach MySQL, DNS and
+	// the destinations of its rules. WordPress fetches updates and plugins
+	// over HTTPS and Stateless instances reach their uploads bucket, allow
+	// them here when they are needed.
+	// +optional
+	WordpressEgress *EgressPolicy `json:"wordpressEgress,omitempty"`
+}
+
+// EgressPolicy lists the destinations allowed on top of the ones the
+// instance needs
+type EgressPolicy struct {
+	// +optional
+	Rules []networkingv1.NetworkPolicyEgressRule `json:"rules,omitempty"`
+}
+
+// DisruptionBudgetsSpec overrides the PodDisruptionBudgets of each tier.
+// By default a tier running more than one pod lets one of them be evicted at
+// a time, and a tier running a single pod has no budget, so that node drains
+// are never blocked.
+type DisruptionBudgetsSpec struct {
+	// +optional
+	Wordpress *DisruptionBudget `json:"wordpress,omitempty"`
+
+	// +optional
+	Mysql *DisruptionBudget `json:"mysql,omitempty"`
+}
+
+// DisruptionBudget sets either MinAvailable or MaxUnavailable. A budget
+// that is set is created whatever the number of pods, a single pod with
+// MinAvailable 1 blocks node drains until it is moved by hand.
+type DisruptionBudget struct {
+	// MinAvailable is the number or percentage of pods that must stay up
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of pods that may be down
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ProbesSpec overrides the probes of each tier
+type ProbesSpec struct {
+	// Wordpress overrides the probes of the WordPress container. By default
+	// readiness requests /wp-admin/install.php, which fails while the
+	// database cannot be reached, and liveness and startup only check that
+	// the port is open.
+	// +optional
+	Wordpress *ContainerProbes `json:"wordpress,omitempty"`
+
+	// Mysql overrides the probes of the MySQL container. By default
+	// readiness runs a query, liveness and startup run mysqladmin ping.
+	// +optional
+	Mysql *ContainerProbes `json:"mysql,omitempty"`
+}
+
+// ContainerProbes replaces the default probes of a container. Each probe
+// that is set replaces its default as a whole.
+type ContainerProbes struct {
+	// +optional
+	Readiness *corev1.Probe `json:"readiness,omitempty"`
+
+	// +optional
+	Liveness *corev1.Probe `json:"liveness,omitempty"`
+
+	// +optional
+	Startup *corev1.Probe `json:"startup,omitempty"`
+}
+
+// ServiceSpec configures the WordPress Service
+type ServiceSpec struct {
+	// Type of the Service, ClusterIP, NodePort or LoadBalancer. Defaults to
+	// NodePort.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Annotations added to the Service, for example to configure the load
+	// balancer of a cloud provider
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// IngressSpec configures the Ingress of the site
+type IngressSpec struct {
+	// Hosts the site is served on. The first one makes up the public URL
+	// of the site, WP_HOME and WP_SITEURL.
+	// +kubebuilder:validation:MinItems=1
+	Hosts []string `json:"hosts"`
+
+	// IngressClassName selects the ingress controller. Defaults to the
+	// default class of the cluster.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// Annotations added to the Ingress
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// TLS serves the site over HTTPS
+	// +optional
+	TLS *IngressTLS `json:"tls,omitempty"`
+}
+
+// IngressTLS is where the certificate of the site comes from
+type IngressTLS struct {
+	// SecretName is the Secret holding the certificate. When an issuer is
+	// set cert-manager writes the certificate to it. Defaults to
+	// <name>-tls of the instance.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Issuer has cert-manager issue the certificate
+	// +optional
+	Issuer *IssuerReference `json:"issuer,omitempty"`
+}
+
+// IssuerReference selects a cert-manager issuer
+type IssuerReference struct {
+	// Name of the issuer
+	Name string `json:"name"`
+
+	// Kind is Issuer, in the namespace of the instance, or ClusterIssuer.
+	// Defaults to Issuer.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// GatewaySpec configures the HTTPRoute of the site. TLS is terminated by
+// the listeners of the Gateway.
+type GatewaySpec struct {
+	// ParentRefs are the Gateways the route attaches to
+	// +kubebuilder:validation:MinItems=1
+	ParentRefs []GatewayReference `json:"parentRefs"`
+
+	// Hostnames the route matches. The first one makes up the public URL of
+	// the site when there is no Ingress.
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+
+	// HTTPS tells that the listeners terminate TLS, so that the public URL
+	// of the site starts with https
+	// +optional
+	HTTPS bool `json:"https,omitempty"`
+}
+
+// GatewayReference selects a Gateway, and optionally one of its listeners
+type GatewayReference struct {
+	// Name of the Gateway
+	Name string `json:"name"`
+
+	// Namespace of the Gateway. Defaults to the namespace of the instance.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// SectionName is the listener of the Gateway to attach to
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// BackupSpec configures the scheduled database backups
+type BackupSpec struct {
+	// Enabled turns the scheduled backups on or off. Turning them off keeps
+	// the backups taken so far. Defaults to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Schedule is the cron schedule backups are taken on. Each run creates a
+	// WordpressBackup named after the instance and the scheduled time.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// IncludeContent also archives the files of the site with each scheduled
+	// backup, see WordpressBackupSpec. Defaults to true.
+	// +optional
+	IncludeContent *bool `json:"includeContent,omitempty"`
+
+	// Method is how scheduled backups are taken. Defaults to Dump. When the
+	// cluster does not support VolumeSnapshots, scheduled backups fall back
+	// to dumps.
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+
+	// VolumeSnapshotClassName is the class of the VolumeSnapshots taken by
+	// VolumeSnapshot backups. Defaults to the default class of the driver.
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// Retention decides which scheduled backups are pruned, along with their
+	// backup sets or VolumeSnapshots. Backups created by hand are kept until
+	// they are deleted.
+	// +optional
+	Retention *BackupRetention `json:"retention,omitempty"`
+
+	// Destination is where dumps are written to. Defaults to the backup volume.
+	// VolumeSnapshots stay in the cluster.
+	// +optional
+	Destination *BackupDestination `json:"destination,omitempty"`
+}
+
+// BackupDestination selects where backups are written to
+type BackupDestination struct {
+	// S3 uploads backups to S3-compatible object storage instead of the
+	// backup volume
+	// +optional
+	S3 *S3Destination `json:"s3,omitempty"`
+}
+
+// S3Destination is a bucket in S3-compatible object storage, such as AWS S3
+// or MinIO
+type S3Destination struct {
+	// Bucket backups are uploaded to
+	Bucket string `json:"bucket"`
+
+	// Prefix of the object keys. Defaults to <namespace>/<name> of the
+	// instance, so several sites can share a bucket.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Endpoint of an S3-compatible service, for example
+	// http://minio.minio.svc:9000. Defaults to AWS S3.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region of the bucket
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// ForcePathStyle puts the bucket in the URL path rather than the host
+	// name, which MinIO and most S3-compatible services expect
+	// +optional
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+
+	// CredentialsSecretRef is a Secret in the namespace of the instance with
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY keys
+	CredentialsSecretRef corev1.LocalObjectReference `json:"credentialsSecretRef"`
+
+	// Encryption requests server-side encryption of the uploaded backups
+	// +optional
+	Encryption *S3Encryption `json:"encryption,omitempty"`
+
+	// Image is the AWS CLI image backups are uploaded with
+	// +optional
+	Image string `json:"image,omitempty"`
+}
+
+// S3Encryption configures server-side encryption
+type S3Encryption struct {
+	// Algorithm is AES256 for keys managed by S3, or aws:kms for KMS keys
+	// +kubebuilder:validation:Enum=AES256;"aws:kms"
+	Algorithm string `json:"algorithm"`
+
+	// KMSKeyID is the KMS key used with aws:kms. Defaults to the AWS managed key.
+	// +optional
+	KMSKeyID string `json:"kmsKeyID,omitempty"`
+}
+
+// BackupRetention limits how many backups are kept. A backup is pruned as
+// soon as it falls outside either limit, by deleting its WordpressBackup.
+type BackupRetention struct {
+	// Count is the number of most recent successful backups to keep
+	// +optional
+	Count *int32 `json:"count,omitempty"`
+
+	// MaxAge is how long a backup is kept, for example 720h for 30 days
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
 }
 
+// WordpressVariant is the flavour of the official WordPress image
+// +kubebuilder:validation:Enum=apache;fpm
+type WordpressVariant string
+
+const (
+	// VariantApache serves the site with the Apache web server built into the image
+	VariantApache WordpressVariant = "apache"
+	// VariantFPM runs PHP-FPM behind an nginx sidecar
+	VariantFPM WordpressVariant = "fpm"
+)
+
+// Default images, used when the spec leaves them empty
+const (
+	DefaultWordpressRepository = "wordpress"
+	DefaultWordpressTag        = "5.4"
+	DefaultWordpressVariant    = VariantApache
+	DefaultWebServerImage      = "nginx:1.25-alpine"
+	DefaultDatabaseImage       = "mysql:8.0"
+	DefaultS3Image             = "amazon/aws-cli:2.15.0"
+)
+
+// LegacyDatabaseImage is the database image of instances that keep legacy
+// names. They were installed before the image was configurable and ran
+// whichever version the unpinned image had then.
+const LegacyDatabaseImage = "mysql"
+
+// Defaults of the other spec fields
+const (
+	DefaultVolumeSize           = "10Gi"
+	DefaultBackupSchedule       = "0 3 * * *"
+	DefaultBackupRetentionCount = 7
+)
+
+// WordpressImage selects the WordPress image. The image reference is
+// <repository>:<tag>-<variant>, following the tags of the official image.
+type WordpressImage struct {
+	// Repository of the WordPress image
+	// +optional
+	Repository string `json:"repository,omitempty"`
+
+	// Tag is the WordPress version, without the variant suffix
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Variant is either apache or fpm
+	// +optional
+	Variant WordpressVariant `json:"variant,omitempty"`
+
+	// WebServerImage is the nginx image put in front of the fpm variant
+	// +optional
+	WebServerImage string `json:"webServerImage,omitempty"`
+}
+
+// LegacyNamingAnnotation is set to "true" on instances that keep the fixed
+// object names (wordpress, wordpress-mysql, wp-pv-claim, ...) used before
+// names were derived from the CR name. The operator sets it when it finds
+// such objects owned by the instance.
+const LegacyNamingAnnotation = "wordpress.gopkg.blogpost.com/legacy-naming"
+
+// RotateCredentialsAnnotation requests a rotation of the generated database
+// passwords whenever its value changes, for example when it is set to the
+// current date.
+const RotateCredentialsAnnotation = "wordpress.gopkg.blogpost.com/rotate-credentials"
+
+// RetainedFromAnnotation is set on the volumes and Secrets an instance left
+// behind under the Retain deletion policy. Its value is the name of the
+// instance, which adopts them again when it is created anew.
+const RetainedFromAnnotation = "wordpress.gopkg.blogpost.com/retained-from"
+
+// Condition types reported in WordpressStatus.Conditions
+const (
+	// ConditionMysqlReady is true when the MySQL tier has all its replicas ready
+	ConditionMysqlReady = "MysqlReady"
+	// ConditionDatabaseUserReady is true when the database user WordPress connects as exists
+	ConditionDatabaseUserReady = "DatabaseUserReady"
+	// ConditionWordpressReady is true when the WordPress tier has all its replicas ready
+	ConditionWordpressReady = "WordpressReady"
+	// ConditionBackupScheduled is true when backups are taken on schedule
+	ConditionBackupScheduled = "BackupScheduled"
+	// ConditionBackupSucceeded tells whether the last backup reached its destination
+	ConditionBackupSucceeded = "BackupSucceeded"
+	// ConditionReady summarises the other conditions
+	ConditionReady = "Ready"
+)
+
+// WordpressPhase is a short summary of where the instance is in its lifecycle
+// +kubebuilder:validation:Enum=Provisioning;Ready;Failed;Deleting
+type WordpressPhase string
+
+const (
+	// PhaseProvisioning means resources are being created or are not ready yet
+	PhaseProvisioning WordpressPhase = "Provisioning"
+	// PhaseReady means every tier is up and serving
+	PhaseReady WordpressPhase = "Ready"
+	// PhaseFailed means reconciliation hit an error it could not recover from
+	PhaseFailed WordpressPhase = "Failed"
+	// PhaseDeleting means the deletion policy is being carried out
+	PhaseDeleting WordpressPhase = "Deleting"
+)
+
 // WordpressStatus defines the observed state of Wordpress
 type WordpressStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Conditions represent the latest available observations of the instance
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Phase is a summary of the conditions
+	// +optional
+	Phase WordpressPhase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation last processed by the controller
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// WordpressReadyReplicas is the number of ready WordPress pods
+	// +optional
+	WordpressReadyReplicas int32 `json:"wordpressReadyReplicas,omitempty"`
+
+	// MysqlReadyReplicas is the number of ready MySQL pods
+	// +optional
+	MysqlReadyReplicas int32 `json:"mysqlReadyReplicas,omitempty"`
+
+	// URL is the address the site is served on, its public URL when it is
+	// exposed through an Ingress or a Gateway
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// Backup reports on the backups of the instance
+	// +optional
+	Backup *BackupStatus `json:"backup,omitempty"`
+
+	// LastCredentialRotation is when the generated database passwords were last rotated
+	// +optional
+	LastCredentialRotation *metav1.Time `json:"lastCredentialRotation,omitempty"`
+
+	// ObservedRotationRequest is the value of RotateCredentialsAnnotation the
+	// last rotation was made for
+	// +optional
+	ObservedRotationRequest string `json:"observedRotationRequest,omitempty"`
+
+	// Volumes reports on the size of each volume of the instance
+	// +listType=map
+	// +listMapKey=claimName
+	// +optional
+	Volumes []VolumeStatus `json:"volumes,omitempty"`
+}
+
+// VolumeStatus reports on the size of a PersistentVolumeClaim of the instance
+type VolumeStatus struct {
+	// ClaimName is the name of the PersistentVolumeClaim
+	ClaimName string `json:"claimName"`
+
+	// Volume is the field of spec.storage the claim is configured by
+	Volume string `json:"volume"`
+
+	// Requested is the size the spec asks for
+	// +optional
+	Requested *resource.Quantity `json:"requested,omitempty"`
+
+	// Capacity is the size the volume has
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+
+	// Expansion tells how growing the volume to the requested size is
+	// going. It is empty when the volume has the requested size.
+	// +optional
+	Expansion VolumeExpansion `json:"expansion,omitempty"`
+
+	// Message explains the state of the expansion
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// VolumeExpansion is the state of the expansion of a volume
+// +kubebuilder:validation:Enum=Resizing;FileSystemResizePending;Unsupported
+type VolumeExpansion string
+
+const (
+	// VolumeResizing means the storage provider is growing the volume
+	VolumeResizing VolumeExpansion = "Resizing"
+	// VolumeFileSystemResizePending means the volume has grown and its file
+	// system grows the next time a pod mounts it
+	VolumeFileSystemResizePending VolumeExpansion = "FileSystemResizePending"
+	// VolumeExpansionUnsupported means the volume cannot be resized to the
+	// requested size
+	VolumeExpansionUnsupported VolumeExpansion = "Unsupported"
+)
+
+// BackupStatus reports on the backups of the instance
+type BackupStatus struct {
+	// Destination the backups are written to, for example s3://bucket/prefix
+	// +optional
+	Destination string `json:"destination,omitempty"`
+
+	// LastScheduleTime is when the last scheduled backup was started
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// NextScheduleTime is when the next scheduled backup is due
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// LastBackup is the name of the last WordpressBackup that succeeded
+	// +optional
+	LastBackup string `json:"lastBackup,omitempty"`
+
+	// LastSuccessfulTime is when the last backup reached its destination
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// LastFailureTime is when a backup last failed
+	// +optional
+	LastFailureTime *metav1.Time `json:"lastFailureTime,omitempty"`
+
+	// LastFailureMessage explains the last failure
+	// +optional
+	LastFailureMessage string `json:"lastFailureMessage,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.url`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Wordpress is the Schema for the wordpresses API
 type Wordpress struct {
@@ -0,0 +1,539 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var wordpresslog = logf.Log.WithName("wordpress-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *Wordpress) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-wordpress-gopkg-blogpost-com-v1alpha1-wordpress,mutating=true,failurePolicy=fail,sideEffects=None,groups=wordpress.gopkg.blogpost.com,resources=wordpresses,verbs=create;update,versions=v1alpha1,name=mwordpress.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Wordpress{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Wordpress) Default() {
+	wordpresslog.Info("default", "name", r.Name)
+
+	if r.Spec.Replicas == nil {
+		replicas := int32(1)
+		r.Spec.Replicas = &replicas
+	}
+	if r.Spec.MysqlReplicas == nil {
+		mysqlReplicas := int32(1)
+		r.Spec.MysqlReplicas = &mysqlReplicas
+	}
+
+	// Pin the image versions on the object, so that a new operator release
+	// with newer defaults does not upgrade existing sites behind their back.
+	// The backup image is left empty on purpose, it follows the database image.
+	if r.Spec.Image == nil {
+		r.Spec.Image = &WordpressImage{}
+	}
+	if r.Spec.Image.Repository == "" {
+		r.Spec.Image.Repository = DefaultWordpressRepository
+	}
+	if r.Spec.Image.Tag == "" {
+		r.Spec.Image.Tag = DefaultWordpressTag
+	}
+	if r.Spec.Image.Variant == "" {
+		r.Spec.Image.Variant = DefaultWordpressVariant
+	}
+	// MySQL refuses to start on the data of a newer version, so legacy
+	// instances keep the image they ran. An existing instance the operator
+	// has not looked at yet may be one of them, it is left for the operator.
+	if r.Spec.DatabaseImage == "" {
+		if r.Annotations[LegacyNamingAnnotation] == "true" {
+			r.Spec.DatabaseImage = LegacyDatabaseImage
+		} else if r.ResourceVersion == "" {
+			r.Spec.DatabaseImage = DefaultDatabaseImage
+		}
+	}
+
+	if r.Spec.Storage == nil {
+		r.Spec.Storage = &StorageSpec{}
+	}
+	for _, vol := range []*VolumeSpec{&r.Spec.Storage.Wordpress, &r.Spec.Storage.Mysql, &r.Spec.Storage.Backup} {
+		if vol.Size == nil {
+			size := resource.MustParse(DefaultVolumeSize)
+			vol.Size = &size
+		}
+	}
+
+	if r.Spec.Content == nil {
+		r.Spec.Content = &ContentSpec{}
+	}
+	if r.Spec.Content.Mode == "" {
+		r.Spec.Content.Mode = ContentVolume
+	}
+
+	if r.Spec.Service == nil {
+		r.Spec.Service = &ServiceSpec{}
+	}
+	if r.Spec.Service.Type == "" {
+		r.Spec.Service.Type = corev1.ServiceTypeNodePort
+	}
+
+	if r.Spec.Backup == nil {
+		r.Spec.Backup = &BackupSpec{}
+	}
+	if r.Spec.Backup.Enabled == nil {
+		enabled := true
+		r.Spec.Backup.Enabled = &enabled
+	}
+	if r.Spec.Backup.IncludeContent == nil {
+		includeContent := true
+		r.Spec.Backup.IncludeContent = &includeContent
+	}
+	if r.Spec.Backup.Method == "" {
+		r.Spec.Backup.Method = BackupMethodDump
+	}
+	if r.Spec.Backup.Schedule == "" {
+		r.Spec.Backup.Schedule = DefaultBackupSchedule
+	}
+	if r.Spec.Backup.Retention == nil {
+		count := int32(DefaultBackupRetentionCount)
+		r.Spec.Backup.Retention = &BackupRetention{Count: &count}
+	}
+
+	// Keep the data unless the user asked for it to go
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyRetain
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-wordpress-gopkg-blogpost-com-v1alpha1-wordpress,mutating=false,failurePolicy=fail,sideEffects=None,groups=wordpress.gopkg.blogpost.com,resources=wordpresses,verbs=create;update,versions=v1alpha1,name=vwordpress.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Wordpress{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Wordpress) ValidateCreate() (admission.Warnings, error) {
+	wordpresslog.Info("validate create", "name", r.Name)
+
+	return r.warnings(), r.toInvalid(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Wordpress) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	wordpresslog.Info("validate update", "name", r.Name)
+
+	allErrs := r.validateSpec()
+	if oldWordpress, ok := old.(*Wordpress); ok {
+		allErrs = append(allErrs, r.validateImmutable(oldWordpress)...)
+	}
+	return r.warnings(), r.toInvalid(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Wordpress) ValidateDelete() (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateSpec checks the values of the spec on their own
+func (r *Wordpress) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if r.Spec.Replicas != nil && *r.Spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("replicas"), *r.Spec.Replicas,
+			"must not be negative"))
+	}
+	// The primary is always there, mysqlReplicas counts it
+	if r.Spec.MysqlReplicas != nil && *r.Spec.MysqlReplicas < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("mysqlReplicas"), *r.Spec.MysqlReplicas,
+			"must be at least 1"))
+	}
+
+	if r.Spec.Storage != nil {
+		storagePath := specPath.Child("storage")
+		for _, vol := range []struct {
+			name string
+			spec VolumeSpec
+		}{
+			{"wordpress", r.Spec.Storage.Wordpress},
+			{"mysql", r.Spec.Storage.Mysql},
+			{"backup", r.Spec.Storage.Backup},
+		} {
+			if vol.spec.Size != nil && vol.spec.Size.Sign() <= 0 {
+				allErrs = append(allErrs, field.Invalid(storagePath.Child(vol.name, "size"), vol.spec.Size.String(),
+					"must be greater than zero"))
+			}
+			// The operator writes to every volume it mounts
+			for i, mode := range vol.spec.AccessModes {
+				if mode != corev1.ReadWriteOnce && mode != corev1.ReadWriteMany && mode != corev1.ReadWriteOncePod {
+					allErrs = append(allErrs, field.NotSupported(storagePath.Child(vol.name, "accessModes").Index(i), mode,
+						[]string{string(corev1.ReadWriteOnce), string(corev1.ReadWriteMany), string(corev1.ReadWriteOncePod)}))
+				}
+			}
+			if vol.spec.VolumeMode != nil && *vol.spec.VolumeMode != corev1.PersistentVolumeFilesystem {
+				allErrs = append(allErrs, field.NotSupported(storagePath.Child(vol.name, "volumeMode"), *vol.spec.VolumeMode,
+					[]string{string(corev1.PersistentVolumeFilesystem)}))
+			}
+		}
+	}
+
+	if r.Spec.Content != nil {
+		contentPath := specPath.Child("content")
+		switch r.Spec.Content.Mode {
+		case ContentStateless:
+			uploads := r.Spec.Content.Uploads
+			if uploads == nil {
+				allErrs = append(allErrs, field.Required(contentPath.Child("uploads"),
+					"stateless instances keep their uploads in object storage"))
+				break
+			}
+			if uploads.Bucket == "" {
+				allErrs = append(allErrs, field.Required(contentPath.Child("uploads", "bucket"), ""))
+			}
+			if uploads.CredentialsSecretRef.Name == "" {
+				allErrs = append(allErrs, field.Required(contentPath.Child("uploads", "credentialsSecretRef", "name"), ""))
+			}
+		case ContentShared:
+			if !hasAccessMode(r.wordpressAccessModes(), corev1.ReadWriteMany) {
+				allErrs = append(allErrs, field.Invalid(specPath.Child("storage", "wordpress", "accessModes"),
+					r.Spec.Storage.Wordpress.AccessModes, "must include ReadWriteMany in Shared content mode"))
+			}
+		}
+	}
+	if a := r.Spec.Autoscaling; a != nil && a.MinReplicas != nil && *a.MinReplicas > a.MaxReplicas {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("autoscaling", "minReplicas"), *a.MinReplicas,
+			"must not be greater than maxReplicas"))
+	}
+	// A ReadWriteOncePod volume is only ever mounted by one pod
+	if path, replicas := r.maxReplicas(); replicas > 1 && r.contentMode() != ContentStateless &&
+		hasAccessMode(r.wordpressAccessModes(), corev1.ReadWriteOncePod) {
+		allErrs = append(allErrs, field.Invalid(path, replicas,
+			"more than one replica cannot share a ReadWriteOncePod content volume, use spec.content.mode Shared or Stateless"))
+	}
+
+	if r.Spec.Ingress != nil {
+		ingressPath := specPath.Child("ingress")
+		for i, host := range r.Spec.Ingress.Hosts {
+			if host == "" {
+				allErrs = append(allErrs, field.Required(ingressPath.Child("hosts").Index(i), ""))
+			}
+		}
+		if tls := r.Spec.Ingress.TLS; tls != nil && tls.Issuer != nil && tls.Issuer.Name == "" {
+			allErrs = append(allErrs, field.Required(ingressPath.Child("tls", "issuer", "name"), ""))
+		}
+	}
+	if r.Spec.Gateway != nil {
+		for i, ref := range r.Spec.Gateway.ParentRefs {
+			if ref.Name == "" {
+				allErrs = append(allErrs, field.Required(specPath.Child("gateway", "parentRefs").Index(i).Child("name"), ""))
+			}
+		}
+	}
+
+	if r.Spec.DisruptionBudgets != nil {
+		budgetsPath := specPath.Child("disruptionBudgets")
+		allErrs = append(allErrs, validateDisruptionBudget(budgetsPath.Child("wordpress"), r.Spec.DisruptionBudgets.Wordpress)...)
+		allErrs = append(allErrs, validateDisruptionBudget(budgetsPath.Child("mysql"), r.Spec.DisruptionBudgets.Mysql)...)
+	}
+	if r.Spec.Probes != nil {
+		probesPath := specPath.Child("probes")
+		allErrs = append(allErrs, validateProbes(probesPath.Child("wordpress"), r.Spec.Probes.Wordpress)...)
+		allErrs = append(allErrs, validateProbes(probesPath.Child("mysql"), r.Spec.Probes.Mysql)...)
+	}
+
+	if r.Spec.CredentialsSecretRef != nil && r.Spec.CredentialsSecretRef.Name == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("credentialsSecretRef", "name"), ""))
+	}
+	// Rotations take a while to roll out, they must not overlap
+	if r.Spec.RotationInterval != nil && r.Spec.RotationInterval.Duration < time.Hour {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("rotationInterval"), r.Spec.RotationInterval.Duration.String(),
+			"must be at least 1h"))
+	}
+
+	if r.Spec.Backup != nil && r.Spec.Backup.Schedule != "" {
+		if _, err := cron.ParseStandard(r.Spec.Backup.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("backup", "schedule"), r.Spec.Backup.Schedule,
+				err.Error()))
+		}
+	}
+	if r.Spec.Backup != nil && r.Spec.Backup.Destination != nil && r.Spec.Backup.Destination.S3 != nil {
+		s3Path := specPath.Child("backup", "destination", "s3")
+		s3 := r.Spec.Backup.Destination.S3
+		if s3.Bucket == "" {
+			allErrs = append(allErrs, field.Required(s3Path.Child("bucket"), ""))
+		}
+		if s3.CredentialsSecretRef.Name == "" {
+			allErrs = append(allErrs, field.Required(s3Path.Child("credentialsSecretRef", "name"), ""))
+		}
+		if s3.Encryption != nil && s3.Encryption.KMSKeyID != "" && s3.Encryption.Algorithm != "aws:kms" {
+			allErrs = append(allErrs, field.Invalid(s3Path.Child("encryption", "kmsKeyID"), s3.Encryption.KMSKeyID,
+				"only applies to the aws:kms algorithm"))
+		}
+	}
+	if r.Spec.Backup != nil && r.Spec.Backup.Retention != nil {
+		retentionPath := specPath.Child("backup", "retention")
+		retention := r.Spec.Backup.Retention
+		if retention.Count != nil && *retention.Count < 1 {
+			allErrs = append(allErrs, field.Invalid(retentionPath.Child("count"), *retention.Count,
+				"must keep at least one backup"))
+		}
+		if retention.MaxAge != nil && retention.MaxAge.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(retentionPath.Child("maxAge"), retention.MaxAge.Duration.String(),
+				"must be greater than zero"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateDisruptionBudget checks that a budget sets exactly one of its fields
+func validateDisruptionBudget(path *field.Path, budget *DisruptionBudget) field.ErrorList {
+	if budget == nil {
+		return nil
+	}
+	if (budget.MinAvailable == nil) == (budget.MaxUnavailable == nil) {
+		return field.ErrorList{field.Invalid(path, "", "must set exactly one of minAvailable or maxUnavailable")}
+	}
+	return nil
+}
+
+// validateProbes checks that each probe override has exactly one handler
+func validateProbes(path *field.Path, probes *ContainerProbes) field.ErrorList {
+	if probes == nil {
+		return nil
+	}
+	allErrs := field.ErrorList{}
+	for _, probe := range []struct {
+		name  string
+		probe *corev1.Probe
+	}{
+		{"readiness", probes.Readiness},
+		{"liveness", probes.Liveness},
+		{"startup", probes.Startup},
+	} {
+		if probe.probe == nil {
+			continue
+		}
+		handlers := 0
+		for _, set := range []bool{
+			probe.probe.Exec != nil,
+			probe.probe.HTTPGet != nil,
+			probe.probe.TCPSocket != nil,
+			probe.probe.GRPC != nil,
+		} {
+			if set {
+				handlers++
+			}
+		}
+		if handlers != 1 {
+			allErrs = append(allErrs, field.Invalid(path.Child(probe.name), handlers,
+				"must set exactly one of exec, httpGet, tcpSocket or grpc"))
+		}
+	}
+	return allErrs
+}
+
+// warnings returns the deprecations the spec relies on
+func (r *Wordpress) warnings() admission.Warnings {
+	var warnings admission.Warnings
+	if r.Spec.SqlRootPassword != "" {
+		if r.Spec.CredentialsSecretRef != nil {
+			warnings = append(warnings, "spec.sqlRootPassword is ignored because spec.credentialsSecretRef is set")
+		} else {
+			warnings = append(warnings, "spec.sqlRootPassword is deprecated, use spec.credentialsSecretRef instead")
+		}
+	}
+	if path, replicas := r.maxReplicas(); replicas > 1 && r.contentMode() != ContentStateless &&
+		!hasAccessMode(r.wordpressAccessModes(), corev1.ReadWriteMany) && !hasAccessMode(r.wordpressAccessModes(), corev1.ReadWriteOncePod) {
+		warnings = append(warnings, path.String()+" is above 1 with a ReadWriteOnce content volume, every replica has to run on "+
+			"the node the volume is attached to. Use spec.content.mode Shared or Stateless to spread them out.")
+	}
+	if r.Spec.Service != nil && r.Spec.Service.Type == corev1.ServiceTypeClusterIP && r.Spec.Ingress == nil && r.Spec.Gateway == nil {
+		warnings = append(warnings, "spec.service.type is ClusterIP without spec.ingress or spec.gateway, the site is only reachable from inside the cluster")
+	}
+	if np := r.Spec.NetworkPolicies; np != nil && np.WordpressEgress != nil && len(np.WordpressEgress.Rules) == 0 && r.contentMode() == ContentStateless {
+		warnings = append(warnings, "spec.networkPolicies.wordpressEgress has no rules, WordPress cannot reach its uploads bucket")
+	}
+	if r.Spec.Content != nil && r.Spec.Content.Uploads != nil && r.contentMode() != ContentStateless {
+		warnings = append(warnings, "spec.content.uploads only applies to the Stateless content mode")
+	}
+	if r.Annotations[LegacyNamingAnnotation] == "true" && r.Spec.DatabaseImage == LegacyDatabaseImage {
+		warnings = append(warnings, "spec.databaseImage is the unpinned mysql image, pin it to the MySQL version the site runs")
+	}
+	if b := r.Spec.Backup; b != nil && b.Method == BackupMethodVolumeSnapshot && b.Destination != nil && b.Destination.S3 != nil {
+		warnings = append(warnings, "spec.backup.destination only applies to dumps, VolumeSnapshots stay in the cluster")
+	}
+	return warnings
+}
+
+// validateImmutable rejects changes to fields that cannot be applied to
+// existing objects
+func (r *Wordpress) validateImmutable(old *Wordpress) field.ErrorList {
+	var allErrs field.ErrorList
+	storagePath := field.NewPath("spec", "storage")
+
+	oldStorage, newStorage := old.Spec.Storage, r.Spec.Storage
+	if oldStorage == nil {
+		oldStorage = &StorageSpec{}
+	}
+	if newStorage == nil {
+		newStorage = &StorageSpec{}
+	}
+	for _, vol := range []struct {
+		name     string
+		old, new VolumeSpec
+	}{
+		{"wordpress", oldStorage.Wordpress, newStorage.Wordpress},
+		{"mysql", oldStorage.Mysql, newStorage.Mysql},
+		{"backup", oldStorage.Backup, newStorage.Backup},
+	} {
+		if stringValue(vol.old.StorageClassName) != stringValue(vol.new.StorageClassName) {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child(vol.name, "storageClassName"),
+				"cannot be changed once the volume exists"))
+		}
+		if !equality.Semantic.DeepEqual(accessModes(vol.old), accessModes(vol.new)) {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child(vol.name, "accessModes"),
+				"cannot be changed once the volume exists"))
+		}
+		if volumeMode(vol.old) != volumeMode(vol.new) {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child(vol.name, "volumeMode"),
+				"cannot be changed once the volume exists"))
+		}
+		if oldSize, newSize := volumeSize(vol.old), volumeSize(vol.new); newSize.Cmp(oldSize) < 0 {
+			allErrs = append(allErrs, field.Forbidden(storagePath.Child(vol.name, "size"),
+				fmt.Sprintf("cannot be decreased from %s to %s, volumes can only grow", oldSize.String(), newSize.String())))
+		}
+	}
+
+	if old.contentMode() != r.contentMode() {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "content", "mode"),
+			"cannot be changed once the instance exists"))
+	}
+
+	if dataSourceBackup(old) != dataSourceBackup(r) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "dataSource"),
+			"cannot be changed once the volumes exist"))
+	}
+
+	return allErrs
+}
+
+// toInvalid wraps validation errors into the error the API server expects
+func (r *Wordpress) toInvalid(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("Wordpress").GroupKind(), r.Name, allErrs)
+}
+
+// dataSourceBackup returns the backup an instance was cloned from, if any
+func dataSourceBackup(r *Wordpress) string {
+	if r.Spec.DataSource == nil {
+		return ""
+	}
+	return r.Spec.DataSource.BackupRef.Name
+}
+
+// contentMode returns where the files of the site live, Volume by default
+func (r *Wordpress) contentMode() ContentMode {
+	if r.Spec.Content == nil || r.Spec.Content.Mode == "" {
+		return ContentVolume
+	}
+	return r.Spec.Content.Mode
+}
+
+// maxReplicas returns the highest number of WordPress replicas the spec
+// allows, and the field it comes from
+func (r *Wordpress) maxReplicas() (*field.Path, int32) {
+	if r.Spec.Autoscaling != nil {
+		return field.NewPath("spec", "autoscaling", "maxReplicas"), r.Spec.Autoscaling.MaxReplicas
+	}
+	if r.Spec.Replicas != nil {
+		return field.NewPath("spec", "replicas"), *r.Spec.Replicas
+	}
+	return field.NewPath("spec", "replicas"), 1
+}
+
+// wordpressAccessModes returns the access modes of the content volume,
+// ReadWriteMany by default in Shared mode
+func (r *Wordpress) wordpressAccessModes() []corev1.PersistentVolumeAccessMode {
+	var vol VolumeSpec
+	if r.Spec.Storage != nil {
+		vol = r.Spec.Storage.Wordpress
+	}
+	if len(vol.AccessModes) == 0 && r.contentMode() == ContentShared {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	return accessModes(vol)
+}
+
+func hasAccessMode(modes []corev1.PersistentVolumeAccessMode, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, m := range modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// accessModes returns the access modes of a volume, ReadWriteOnce by default
+func accessModes(vol VolumeSpec) []corev1.PersistentVolumeAccessMode {
+	if len(vol.AccessModes) == 0 {
+		return []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return vol.AccessModes
+}
+
+// volumeMode returns the volume mode of a volume, Filesystem by default
+func volumeMode(vol VolumeSpec) corev1.PersistentVolumeMode {
+	if vol.VolumeMode == nil {
+		return corev1.PersistentVolumeFilesystem
+	}
+	return *vol.VolumeMode
+}
+
+// volumeSize returns the requested size of a volume, DefaultVolumeSize by default
+func volumeSize(vol VolumeSpec) resource.Quantity {
+	if vol.Size == nil {
+		return resource.MustParse(DefaultVolumeSize)
+	}
+	return *vol.Size
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
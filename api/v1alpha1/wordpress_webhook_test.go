@@ -0,0 +1,224 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("Wordpress Webhook", func() {
+	newWordpress := func(name string) *Wordpress {
+		return &Wordpress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+		}
+	}
+
+	Context("When creating Wordpress under Defaulting Webhook", func() {
+		It("Should fill in the default values", func() {
+			wordpress := newWordpress("defaulted")
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, wordpress)
+
+			created := &Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "defaulted", Namespace: "default"}, created)).To(Succeed())
+			Expect(*created.Spec.Replicas).To(Equal(int32(1)))
+			Expect(*created.Spec.MysqlReplicas).To(Equal(int32(1)))
+			Expect(created.Spec.Image.Tag).To(Equal(DefaultWordpressTag))
+			Expect(created.Spec.DatabaseImage).To(Equal(DefaultDatabaseImage))
+			Expect(created.Spec.BackupImage).To(BeEmpty())
+			Expect(created.Spec.Storage.Mysql.Size.String()).To(Equal(DefaultVolumeSize))
+			Expect(created.Spec.Backup.Schedule).To(Equal(DefaultBackupSchedule))
+			Expect(*created.Spec.Backup.Enabled).To(BeTrue())
+			Expect(*created.Spec.Backup.IncludeContent).To(BeTrue())
+			Expect(created.Spec.Backup.Method).To(Equal(BackupMethodDump))
+			Expect(created.Spec.DeletionPolicy).To(Equal(DeletionPolicyRetain))
+			Expect(created.Spec.Content.Mode).To(Equal(ContentVolume))
+			Expect(created.Spec.Service.Type).To(Equal(corev1.ServiceTypeNodePort))
+			Expect(*created.Spec.Backup.Retention.Count).To(Equal(int32(DefaultBackupRetentionCount)))
+		})
+	})
+
+	Context("When updating Wordpress under Defaulting Webhook", func() {
+		It("Should keep legacy instances on the image they were installed with", func() {
+			wordpress := newWordpress("legacy-image")
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, wordpress)
+
+			wordpress.Annotations = map[string]string{LegacyNamingAnnotation: "true"}
+			wordpress.Spec.DatabaseImage = ""
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+			Expect(wordpress.Spec.DatabaseImage).To(Equal(LegacyDatabaseImage))
+		})
+
+		It("Should leave the image of an existing instance to the operator", func() {
+			wordpress := newWordpress("existing-image")
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, wordpress)
+
+			wordpress.Spec.DatabaseImage = ""
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+			Expect(wordpress.Spec.DatabaseImage).To(BeEmpty())
+		})
+	})
+
+	Context("When creating Wordpress under Validating Webhook", func() {
+		It("Should deny negative replicas", func() {
+			wordpress := newWordpress("negative-replicas")
+			replicas := int32(-1)
+			wordpress.Spec.Replicas = &replicas
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny keeping no backups", func() {
+			wordpress := newWordpress("no-backups-kept")
+			count := int32(0)
+			wordpress.Spec.Backup = &BackupSpec{Retention: &BackupRetention{Count: &count}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny an S3 destination without credentials", func() {
+			wordpress := newWordpress("s3-no-credentials")
+			wordpress.Spec.Backup = &BackupSpec{
+				Destination: &BackupDestination{S3: &S3Destination{Bucket: "backups"}},
+			}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny a malformed backup schedule", func() {
+			wordpress := newWordpress("bad-schedule")
+			wordpress.Spec.Backup = &BackupSpec{Schedule: "every five minutes"}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny a zero volume size", func() {
+			wordpress := newWordpress("zero-size")
+			size := resource.MustParse("0")
+			wordpress.Spec.Storage = &StorageSpec{Wordpress: VolumeSpec{Size: &size}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny changing the storage class", func() {
+			wordpress := newWordpress("storage-class")
+			fast := "fast"
+			wordpress.Spec.Storage = &StorageSpec{Mysql: VolumeSpec{StorageClassName: &fast}}
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, wordpress)
+
+			slow := "slow"
+			wordpress.Spec.Storage.Mysql.StorageClassName = &slow
+			err := k8sClient.Update(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should allow growing a volume but deny shrinking it", func() {
+			wordpress := newWordpress("volume-size")
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, wordpress)
+
+			larger := resource.MustParse("20Gi")
+			wordpress.Spec.Storage.Mysql.Size = &larger
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+
+			smaller := resource.MustParse("15Gi")
+			wordpress.Spec.Storage.Mysql.Size = &smaller
+			err := k8sClient.Update(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("volumes can only grow"))
+		})
+
+		It("Should deny a stateless instance without an uploads bucket", func() {
+			wordpress := newWordpress("stateless")
+			wordpress.Spec.Content = &ContentSpec{Mode: ContentStateless}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny replicas sharing a ReadWriteOncePod content volume", func() {
+			wordpress := newWordpress("read-write-once-pod")
+			replicas := int32(2)
+			wordpress.Spec.Replicas = &replicas
+			wordpress.Spec.Storage = &StorageSpec{Wordpress: VolumeSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOncePod},
+			}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny more autoscaling minReplicas than maxReplicas", func() {
+			wordpress := newWordpress("autoscaling-bounds")
+			minReplicas := int32(5)
+			wordpress.Spec.Autoscaling = &AutoscalingSpec{MinReplicas: &minReplicas, MaxReplicas: 3}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny a disruption budget setting both fields", func() {
+			wordpress := newWordpress("disruption-budget")
+			one := intstr.FromInt(1)
+			wordpress.Spec.DisruptionBudgets = &DisruptionBudgetsSpec{Mysql: &DisruptionBudget{
+				MinAvailable:   &one,
+				MaxUnavailable: &one,
+			}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny a probe without a handler", func() {
+			wordpress := newWordpress("probe-without-handler")
+			wordpress.Spec.Probes = &ProbesSpec{Mysql: &ContainerProbes{
+				Readiness: &corev1.Probe{PeriodSeconds: 5},
+			}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+
+		It("Should deny block volumes", func() {
+			wordpress := newWordpress("block-volume")
+			block := corev1.PersistentVolumeBlock
+			wordpress.Spec.Storage = &StorageSpec{Wordpress: VolumeSpec{VolumeMode: &block}}
+
+			err := k8sClient.Create(ctx, wordpress)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels set on the WordpressBackups the operator creates on schedule
+const (
+	// BackupInstanceLabel names the Wordpress instance a backup was taken of
+	BackupInstanceLabel = "wordpress.gopkg.blogpost.com/instance"
+	// ScheduledBackupLabel is "true" on backups taken on schedule. Only those
+	// are pruned by the retention of the instance.
+	ScheduledBackupLabel = "wordpress.gopkg.blogpost.com/scheduled"
+)
+
+// BackupMethod is how a backup is taken
+// +kubebuilder:validation:Enum=Dump;VolumeSnapshot
+type BackupMethod string
+
+const (
+	// BackupMethodDump writes a logical dump of the database, and an archive
+	// of the content, to the backup destination of the instance
+	BackupMethodDump BackupMethod = "Dump"
+	// BackupMethodVolumeSnapshot takes CSI VolumeSnapshots of the database and
+	// content volumes while the tables are locked. It needs the
+	// snapshot.storage.k8s.io CRDs and a CSI driver supporting snapshots.
+	BackupMethodVolumeSnapshot BackupMethod = "VolumeSnapshot"
+)
+
+// Volumes a VolumeSnapshot backup takes snapshots of
+const (
+	// SnapshotVolumeDatabase is the volume of the MySQL primary
+	SnapshotVolumeDatabase = "database"
+	// SnapshotVolumeContent is the volume holding /var/www/html
+	SnapshotVolumeContent = "content"
+)
+
+// WordpressBackupSpec defines the desired state of WordpressBackup
+type WordpressBackupSpec struct {
+	// WordpressRef is the instance to back up, in the namespace of the backup.
+	// The backup is written to the destination configured on the instance.
+	WordpressRef corev1.LocalObjectReference `json:"wordpressRef"`
+
+	// Method is how the backup is taken. Defaults to the method of the
+	// scheduled backups of the instance.
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+
+	// IncludeContent also archives the files of the site, uploads, themes and
+	// plugins included. The content is archived right after the database is
+	// dumped, so that every file the dump refers to is in the backup set.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	// +optional
+	IncludeContent *bool `json:"includeContent,omitempty"`
+}
+
+// BackupPhase is where a backup is in its lifecycle
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed
+type BackupPhase string
+
+const (
+	// BackupPending means the backup Job has not started yet
+	BackupPending BackupPhase = "Pending"
+	// BackupRunning means the backup Job is running
+	BackupRunning BackupPhase = "Running"
+	// BackupSucceeded means the backup set is complete at its location
+	BackupSucceeded BackupPhase = "Succeeded"
+	// BackupFailed means the backup Job gave up
+	BackupFailed BackupPhase = "Failed"
+)
+
+// WordpressBackupStatus defines the observed state of WordpressBackup
+type WordpressBackupStatus struct {
+	// Phase of the backup
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Method the backup was taken with
+	// +optional
+	Method BackupMethod `json:"method,omitempty"`
+
+	// StartTime is when the backup Job started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the backup Job finished, successfully or not
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Location of the backup set, for example s3://bucket/prefix/name
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// ClaimName is the backup volume the backup set was written to, when it
+	// did not go to object storage
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// S3 is the object storage the backup set was written to. It is copied
+	// from the instance so the backup can still be found, and deleted, after
+	// the instance changes destination.
+	// +optional
+	S3 *S3Destination `json:"s3,omitempty"`
+
+	// Size of the backup set in bytes
+	// +optional
+	Size int64 `json:"size,omitempty"`
+
+	// Checksum is the SHA-256 digest of the SHA256SUMS file of the backup set,
+	// which in turn lists the digest of every file in the set
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// IncludesContent tells whether the backup set holds the files of the
+	// site. It is false when the instance keeps no content volume, even if
+	// the spec asked for the content.
+	// +optional
+	IncludesContent *bool `json:"includesContent,omitempty"`
+
+	// Files of the backup set, as listed in its manifest
+	// +optional
+	Files []BackupFile `json:"files,omitempty"`
+
+	// VolumeSnapshots taken by a VolumeSnapshot backup
+	// +optional
+	VolumeSnapshots []BackupVolumeSnapshot `json:"volumeSnapshots,omitempty"`
+
+	// Message explains the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// BackupFile is a file of a backup set
+type BackupFile struct {
+	// Name of the file within the backup set
+	Name string `json:"name"`
+
+	// Size of the file in bytes
+	Size int64 `json:"size"`
+
+	// Checksum is the SHA-256 digest of the file
+	Checksum string `json:"checksum"`
+}
+
+// BackupVolumeSnapshot is a VolumeSnapshot taken by a backup
+type BackupVolumeSnapshot struct {
+	// Volume the snapshot was taken of, database or content
+	Volume string `json:"volume"`
+
+	// Name of the VolumeSnapshot, in the namespace of the backup
+	Name string `json:"name"`
+
+	// ReadyToUse tells whether volumes can be created from the snapshot
+	// +optional
+	ReadyToUse bool `json:"readyToUse,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=wpbackup
+//+kubebuilder:printcolumn:name="Wordpress",type=string,JSONPath=`.spec.wordpressRef.name`
+//+kubebuilder:printcolumn:name="Method",type=string,JSONPath=`.status.method`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.size`
+//+kubebuilder:printcolumn:name="Location",type=string,JSONPath=`.status.location`,priority=1
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WordpressBackup is the Schema for the wordpressbackups API
+type WordpressBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WordpressBackupSpec   `json:"spec,omitempty"`
+	Status WordpressBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WordpressBackupList contains a list of WordpressBackup
+type WordpressBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WordpressBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WordpressBackup{}, &WordpressBackupList{})
+}
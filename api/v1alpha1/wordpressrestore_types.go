@@ -0,0 +1,154 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RestoreInProgressAnnotation is set on a Wordpress by the restore that is
+// overwriting it. WordPress is scaled to zero and no backups are taken while
+// it is set.
+const RestoreInProgressAnnotation = "wordpress.gopkg.blogpost.com/restore-in-progress"
+
+// WordpressRestoreSpec defines the desired state of WordpressRestore
+// +kubebuilder:validation:XValidation:rule="has(self.backupRef) != has(self.location)",message="exactly one of backupRef and location must be set"
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type WordpressRestoreSpec struct {
+	// WordpressRef is the instance to restore, in the namespace of the restore.
+	// Its database and content are replaced by those of the backup.
+	WordpressRef corev1.LocalObjectReference `json:"wordpressRef"`
+
+	// BackupRef is the WordpressBackup to restore from. It must have succeeded.
+	// +optional
+	BackupRef *corev1.LocalObjectReference `json:"backupRef,omitempty"`
+
+	// Location of a backup set to restore from, for backup sets without a
+	// WordpressBackup, such as those of a deleted instance
+	// +optional
+	Location *BackupLocation `json:"location,omitempty"`
+}
+
+// BackupLocation is where a backup set is stored
+// +kubebuilder:validation:XValidation:rule="has(self.claimName) != has(self.s3)",message="exactly one of claimName and s3 must be set"
+type BackupLocation struct {
+	// Name of the backup set, the name of the WordpressBackup that took it
+	Name string `json:"name"`
+
+	// ClaimName is the backup volume holding the backup set
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// S3 is the object storage holding the backup set. The prefix defaults to
+	// <namespace>/<name> of the restored instance.
+	// +optional
+	S3 *S3Destination `json:"s3,omitempty"`
+}
+
+// RestorePhase is the stage a restore is at
+// +kubebuilder:validation:Enum=Pending;ScalingDown;RestoringDatabase;RestoringContent;ScalingUp;Succeeded;Failed
+type RestorePhase string
+
+const (
+	// RestorePending means the restore waits for its source or for another
+	// restore of the instance
+	RestorePending RestorePhase = "Pending"
+	// RestoreScalingDown means WordPress is being stopped
+	RestoreScalingDown RestorePhase = "ScalingDown"
+	// RestoringDatabase means a Job is loading the database dump
+	RestoringDatabase RestorePhase = "RestoringDatabase"
+	// RestoringContent means a Job is unpacking the site content
+	RestoringContent RestorePhase = "RestoringContent"
+	// RestoreScalingUp means WordPress is being started again
+	RestoreScalingUp RestorePhase = "ScalingUp"
+	// RestoreSucceeded means the site serves the restored backup
+	RestoreSucceeded RestorePhase = "Succeeded"
+	// RestoreFailed means the restore gave up
+	RestoreFailed RestorePhase = "Failed"
+)
+
+// Condition types reported on a WordpressRestore, one per stage
+const (
+	// RestoreConditionScaledDown is true once WordPress has stopped
+	RestoreConditionScaledDown = "ScaledDown"
+	// RestoreConditionDatabaseRestored is true once the database dump is loaded
+	RestoreConditionDatabaseRestored = "DatabaseRestored"
+	// RestoreConditionContentRestored is true once the site content is unpacked
+	RestoreConditionContentRestored = "ContentRestored"
+	// RestoreConditionScaledUp is true once WordPress serves again
+	RestoreConditionScaledUp = "ScaledUp"
+)
+
+// WordpressRestoreStatus defines the observed state of WordpressRestore
+type WordpressRestoreStatus struct {
+	// Phase is the stage the restore is at
+	// +optional
+	Phase RestorePhase `json:"phase,omitempty"`
+
+	// StartTime is when WordPress was stopped for the restore
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore finished, successfully or not
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Location of the backup set restored from
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// Message explains the phase
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Conditions report on each stage of the restore
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=wprestore
+//+kubebuilder:printcolumn:name="Wordpress",type=string,JSONPath=`.spec.wordpressRef.name`
+//+kubebuilder:printcolumn:name="Backup",type=string,JSONPath=`.spec.backupRef.name`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WordpressRestore is the Schema for the wordpressrestores API
+type WordpressRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WordpressRestoreSpec   `json:"spec,omitempty"`
+	Status WordpressRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WordpressRestoreList contains a list of WordpressRestore
+type WordpressRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WordpressRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WordpressRestore{}, &WordpressRestoreList{})
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// defaultTargetCPUUtilization is kept when the spec sets no target at all
+const defaultTargetCPUUtilization = int32(80)
+
+// isAutoscaled reports whether a HorizontalPodAutoscaler owns the number of
+// WordPress replicas
+func isAutoscaled(cr *v1.Wordpress) bool {
+	return cr.Spec.Autoscaling != nil
+}
+
+// minReplicas returns the lowest number of WordPress replicas of an
+// autoscaled instance
+func minReplicas(cr *v1.Wordpress) int32 {
+	if cr.Spec.Autoscaling.MinReplicas != nil {
+		return *cr.Spec.Autoscaling.MinReplicas
+	}
+	return 1
+}
+
+// utilizationMetric returns a metric keeping the average utilisation of a
+// resource of the pods at the target
+func utilizationMetric(name corev1.ResourceName, target int32) autoscalingv2.MetricSpec {
+	return autoscalingv2.MetricSpec{
+		Type: autoscalingv2.ResourceMetricSourceType,
+		Resource: &autoscalingv2.ResourceMetricSource{
+			Name: name,
+			Target: autoscalingv2.MetricTarget{
+				Type:               autoscalingv2.UtilizationMetricType,
+				AverageUtilization: &target,
+			},
+		},
+	}
+}
+
+// Creates the HorizontalPodAutoscaler scaling the WordPress Deployment
+func (r *WordpressReconciler) hpaForWordpress(cr *v1.Wordpress) *autoscalingv2.HorizontalPodAutoscaler {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+	spec := cr.Spec.Autoscaling
+
+	var metrics []autoscalingv2.MetricSpec
+	if spec.TargetCPUUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, *spec.TargetCPUUtilizationPercentage))
+	}
+	if spec.TargetMemoryUtilizationPercentage != nil {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceMemory, *spec.TargetMemoryUtilizationPercentage))
+	}
+	for i := range spec.Metrics {
+		metrics = append(metrics, *spec.Metrics[i].DeepCopy())
+	}
+	if len(metrics) == 0 {
+		metrics = append(metrics, utilizationMetric(corev1.ResourceCPU, defaultTargetCPUUtilization))
+	}
+
+	lowest := minReplicas(cr)
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wordpressName(cr),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       wordpressName(cr),
+			},
+			MinReplicas: &lowest,
+			MaxReplicas: spec.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    spec.Behavior.DeepCopy(),
+		},
+	}
+
+	controllerutil.SetControllerReference(cr, hpa, r.Scheme)
+	return hpa
+}
+
+// autoscaledReplicas leaves the replicas of the WordPress Deployment to the
+// HorizontalPodAutoscaler and returns the number it currently runs. It only
+// sets them when the Deployment is created or was scaled to zero for a
+// restore, the HorizontalPodAutoscaler does not scale up from zero.
+func (r *WordpressReconciler) autoscaledReplicas(cr *v1.Wordpress, dep *appsv1.Deployment) (int32, error) {
+	found := &appsv1.Deployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: dep.Name, Namespace: dep.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return 0, err
+	}
+	if errors.IsNotFound(err) || found.Spec.Replicas == nil || *found.Spec.Replicas == 0 {
+		lowest := minReplicas(cr)
+		dep.Spec.Replicas = &lowest
+		return lowest, nil
+	}
+	dep.Spec.Replicas = nil
+	return *found.Spec.Replicas, nil
+}
+
+func (r *WordpressReconciler) ensureHPA(_ reconcile.Request,
+	instance *v1.Wordpress,
+	hpa *autoscalingv2.HorizontalPodAutoscaler,
+) (*reconcile.Result, error) {
+
+	found := &autoscalingv2.HorizontalPodAutoscaler{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      hpa.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Namespace", hpa.Namespace, "HorizontalPodAutoscaler.Name", hpa.Name)
+		if err := r.Client.Create(context.TODO(), hpa); err != nil {
+			r.Log.Error(err, "Failed to create new HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Namespace", hpa.Namespace, "HorizontalPodAutoscaler.Name", hpa.Name)
+			return &reconcile.Result{}, err
+		}
+		return nil, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get HorizontalPodAutoscaler")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "HorizontalPodAutoscaler"); err != nil {
+		r.Log.Error(err, "Refusing to adopt HorizontalPodAutoscaler")
+		return &ctrl.Result{}, err
+	}
+
+	// The API server fills in the default behavior, DeepDerivative does not
+	// count it as drift
+	labelsChanged := mergeLabels(&found.ObjectMeta, hpa.Labels)
+	if !labelsChanged &&
+		len(hpa.Spec.Metrics) == len(found.Spec.Metrics) &&
+		equality.Semantic.DeepDerivative(hpa.Spec, found.Spec) {
+		return nil, nil
+	}
+
+	found.Spec = hpa.Spec
+	r.Log.Info("Updating HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Namespace", found.Namespace, "HorizontalPodAutoscaler.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Namespace", found.Namespace, "HorizontalPodAutoscaler.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+	return nil, nil
+}
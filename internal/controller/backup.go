@@ -0,0 +1,594 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// A backup set is a directory named after its WordpressBackup, on the backup
+// volume or under the S3 prefix of the instance, holding:
+//
+//	database.sql.gz  the compressed dump of the database
+//	content.tar.gz   the site content, when the backup includes it
+//	manifest.json    what the set was taken of, and its files
+//	SHA256SUMS       the digests of the files above
+//
+// The content is archived after the database is dumped. WordPress writes a
+// file before the row referring to it, so every file the dump refers to is in
+// the archive.
+
+// mysqlDumpScript dumps the database into the backup set
+const mysqlDumpScript = `set -eo pipefail
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+mkdir -p "/backup/$BACKUP_NAME"
+file="/backup/$BACKUP_NAME/database.sql.gz"
+mysqldump -h "$MYSQL_HOST" -u root --single-transaction --routines --triggers wordpress | gzip > "$file.tmp"
+mv "$file.tmp" "$file"
+echo "Wrote $file"
+`
+
+// contentArchiveScript archives the site content into the backup set
+const contentArchiveScript = `set -e
+mkdir -p "/backup/$BACKUP_NAME"
+file="/backup/$BACKUP_NAME/content.tar.gz"
+tar -czf "$file.tmp" -C /var/www/html .
+mv "$file.tmp" "$file"
+echo "Wrote $file"
+`
+
+// s3CLISetup points the AWS CLI at the configured endpoint and collects the
+// encryption arguments of uploads
+const s3CLISetup = `if [ "$S3_FORCE_PATH_STYLE" = "true" ]; then
+  aws configure set default.s3.addressing_style path
+fi
+endpoint=()
+if [ -n "$S3_ENDPOINT" ]; then
+  endpoint=(--endpoint-url "$S3_ENDPOINT")
+fi
+sse=()
+if [ -n "$S3_SSE" ]; then
+  sse=(--sse "$S3_SSE")
+fi
+if [ -n "$S3_SSE_KMS_KEY_ID" ]; then
+  sse+=(--sse-kms-key-id "$S3_SSE_KMS_KEY_ID")
+fi
+`
+
+// backupFinalizeScript writes the manifest of the backup set, checksums the
+// set and uploads it when it goes to S3. The size, checksum and files of the
+// set are reported in the termination message, for the controller to copy
+// into the status of the backup.
+const backupFinalizeScript = `set -eo pipefail
+cd "/backup/$BACKUP_NAME"
+rm -f SHA256SUMS manifest.json
+files=""
+for f in database.sql.gz content.tar.gz; do
+  [ -f "$f" ] || continue
+  sum=$(sha256sum "$f" | cut -d ' ' -f 1)
+  files="$files${files:+,}{\"name\":\"$f\",\"size\":$(stat -c %s "$f"),\"checksum\":\"sha256:$sum\"}"
+done
+cat > manifest.json <<MANIFEST
+{
+  "name": "$BACKUP_NAME",
+  "createdAt": "$(date -u +%Y-%m-%dT%H:%M:%SZ)",
+  "wordpress": {"namespace": "$WORDPRESS_NAMESPACE", "name": "$WORDPRESS_NAME", "image": "$WORDPRESS_IMAGE"},
+  "database": {"name": "wordpress", "image": "$DATABASE_IMAGE"},
+  "files": [$files]
+}
+MANIFEST
+sha256sum -- * > SHA256SUMS
+size=$(du -cb -- * | tail -n 1 | cut -f 1)
+checksum=$(sha256sum SHA256SUMS | cut -d ' ' -f 1)
+if [ -n "$S3_BUCKET" ]; then
+` + s3CLISetup + `
+  aws "${endpoint[@]}" s3 cp --recursive "${sse[@]}" . "s3://$S3_BUCKET/$S3_PREFIX/$BACKUP_NAME/"
+fi
+printf '{"size":%s,"checksum":"sha256:%s","files":[%s]}' "$size" "$checksum" "$files" > /dev/termination-log
+`
+
+// backupCleanupScript removes a backup set
+const backupCleanupScript = `set -eo pipefail
+if [ -n "$S3_BUCKET" ]; then
+` + s3CLISetup + `
+  aws "${endpoint[@]}" s3 rm --recursive "s3://$S3_BUCKET/$S3_PREFIX/$BACKUP_NAME/"
+else
+  rm -rf "/backup/$BACKUP_NAME"
+fi
+`
+
+// backupEnabled reports whether scheduled backups are on
+func backupEnabled(cr *v1.Wordpress) bool {
+	return cr.Spec.Backup == nil || cr.Spec.Backup.Enabled == nil || *cr.Spec.Backup.Enabled
+}
+
+// backupSchedule returns the cron schedule of the backups
+func backupSchedule(cr *v1.Wordpress) string {
+	if cr.Spec.Backup != nil && cr.Spec.Backup.Schedule != "" {
+		return cr.Spec.Backup.Schedule
+	}
+	return v1.DefaultBackupSchedule
+}
+
+// backupIncludesContent reports whether a backup archives the site content.
+// Backups record it in their status when they start, older ones go by
+// their spec.
+func backupIncludesContent(backup *v1.WordpressBackup) bool {
+	if backup.Status.IncludesContent != nil {
+		return *backup.Status.IncludesContent
+	}
+	return backup.Spec.IncludeContent == nil || *backup.Spec.IncludeContent
+}
+
+// recordBackupContent records in the status of a backup whether it archives
+// the site content, which an instance without a content volume has none of
+func recordBackupContent(cr *v1.Wordpress, backup *v1.WordpressBackup) {
+	includesContent := backupIncludesContent(backup) && hasContentVolume(cr)
+	backup.Status.IncludesContent = &includesContent
+}
+
+// scheduledBackupsIncludeContent reports whether the scheduled backups of an
+// instance archive the site content
+func scheduledBackupsIncludeContent(cr *v1.Wordpress) bool {
+	if !hasContentVolume(cr) {
+		return false
+	}
+	return cr.Spec.Backup == nil || cr.Spec.Backup.IncludeContent == nil || *cr.Spec.Backup.IncludeContent
+}
+
+// backupRetention returns the retention limits of the scheduled backups. Only
+// a count is applied when the spec sets neither limit.
+func backupRetention(cr *v1.Wordpress) v1.BackupRetention {
+	var retention v1.BackupRetention
+	if cr.Spec.Backup != nil && cr.Spec.Backup.Retention != nil {
+		retention = *cr.Spec.Backup.Retention
+	}
+	if retention.Count == nil && retention.MaxAge == nil {
+		count := int32(v1.DefaultBackupRetentionCount)
+		retention.Count = &count
+	}
+	return retention
+}
+
+// removeBackupCronJob deletes the CronJob that took the backups before they
+// were WordpressBackups. The backup volume and the backups on it are kept.
+func (r *WordpressReconciler) removeBackupCronJob(cr *v1.Wordpress) error {
+	cj := &batchv1.CronJob{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      backupCronJobName(cr),
+		Namespace: cr.Namespace,
+	}, cj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(cj, cr) {
+		return nil
+	}
+	r.Log.Info("Removing backup CronJob", "CronJob.Namespace", cj.Namespace, "CronJob.Name", cj.Name)
+	return client.IgnoreNotFound(r.Client.Delete(context.TODO(), cj))
+}
+
+// backupJobLabel is set on backup Jobs so the Jobs of an instance can be found
+// again
+const backupJobLabel = "wordpress.gopkg.blogpost.com/backup"
+
+func backupJobLabels(cr *v1.Wordpress) map[string]string {
+	return map[string]string{
+		"app":          cr.Name,
+		backupJobLabel: cr.Name,
+	}
+}
+
+// s3Destination returns the S3 destination of the backups, nil when they go
+// to the backup volume
+func s3Destination(cr *v1.Wordpress) *v1.S3Destination {
+	if cr.Spec.Backup == nil || cr.Spec.Backup.Destination == nil {
+		return nil
+	}
+	return cr.Spec.Backup.Destination.S3
+}
+
+// s3Prefix returns the key prefix of the backups of the instance
+func s3Prefix(cr *v1.Wordpress, s3 *v1.S3Destination) string {
+	if s3.Prefix != "" {
+		return strings.Trim(s3.Prefix, "/")
+	}
+	return cr.Namespace + "/" + cr.Name
+}
+
+// backupDestination describes where the backups are written to
+func backupDestination(cr *v1.Wordpress) string {
+	if s3 := s3Destination(cr); s3 != nil {
+		return "s3://" + s3.Bucket + "/" + s3Prefix(cr, s3)
+	}
+	return "pvc/" + backupPVCName(cr)
+}
+
+// s3Image returns the image the AWS CLI runs from
+func s3Image(s3 *v1.S3Destination) string {
+	if s3.Image != "" {
+		return s3.Image
+	}
+	return v1.DefaultS3Image
+}
+
+// s3Env passes an S3 destination to the AWS CLI scripts. The prefix is taken
+// as is, see recordBackupDestination.
+func s3Env(s3 *v1.S3Destination) []corev1.EnvVar {
+	env := []corev1.EnvVar{
+		{Name: "S3_BUCKET", Value: s3.Bucket},
+		{Name: "S3_PREFIX", Value: s3.Prefix},
+		{Name: "S3_ENDPOINT", Value: s3.Endpoint},
+		{Name: "S3_FORCE_PATH_STYLE", Value: strconv.FormatBool(s3.ForcePathStyle)},
+		secretEnv("AWS_ACCESS_KEY_ID", secretKey(s3.CredentialsSecretRef.Name, "AWS_ACCESS_KEY_ID")),
+		secretEnv("AWS_SECRET_ACCESS_KEY", secretKey(s3.CredentialsSecretRef.Name, "AWS_SECRET_ACCESS_KEY")),
+	}
+	if s3.Region != "" {
+		env = append(env, corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: s3.Region})
+	}
+	if s3.Encryption != nil {
+		env = append(env, corev1.EnvVar{Name: "S3_SSE", Value: s3.Encryption.Algorithm})
+		if s3.Encryption.KMSKeyID != "" {
+			env = append(env, corev1.EnvVar{Name: "S3_SSE_KMS_KEY_ID", Value: s3.Encryption.KMSKeyID})
+		}
+	}
+	return env
+}
+
+// backupStorageVolume is the volume a backup set is written to, the backup
+// volume or, for S3, a scratch volume it is uploaded from
+func backupStorageVolume(backup *v1.WordpressBackup) corev1.Volume {
+	vol := corev1.Volume{Name: "backup-storage"}
+	if backup.Status.S3 != nil {
+		vol.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	} else {
+		vol.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: backup.Status.ClaimName,
+		}
+	}
+	return vol
+}
+
+// jobForBackup creates the Job taking a backup. Init containers dump the
+// database, and archive the site content if asked to, into the backup set.
+// The last container checksums the set and uploads it when it goes to S3.
+func (r *WordpressReconciler) jobForBackup(cr *v1.Wordpress, backup *v1.WordpressBackup) (*batchv1.Job, error) {
+	labels := backupJobLabels(cr)
+
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "BACKUP_NAME",
+			Value: backup.Name,
+		},
+	}
+	backupMounts := []corev1.VolumeMount{
+		{
+			Name:      "backup-storage",
+			MountPath: "/backup",
+		},
+	}
+
+	finalize := corev1.Container{
+		Name:    "finalize",
+		Image:   backupImage(cr),
+		Command: []string{"bash", "-c", backupFinalizeScript},
+		// Recorded in the manifest
+		Env: append([]corev1.EnvVar{
+			{Name: "WORDPRESS_NAMESPACE", Value: cr.Namespace},
+			{Name: "WORDPRESS_NAME", Value: cr.Name},
+			{Name: "WORDPRESS_IMAGE", Value: wordpressImage(cr)},
+			{Name: "DATABASE_IMAGE", Value: mysqlImage(cr)},
+		}, env...),
+		VolumeMounts: backupMounts,
+	}
+	if s3 := backup.Status.S3; s3 != nil {
+		finalize.Image = s3Image(s3)
+		finalize.Env = append(finalize.Env, s3Env(s3)...)
+	}
+
+	backoffLimit := int32(3)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobName(backup),
+			Namespace: backup.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{
+						Name:    "mysql-dump",
+						Image:   backupImage(cr),
+						Command: []string{"bash", "-c", mysqlDumpScript},
+						Env: append([]corev1.EnvVar{
+							{
+								Name:  "MYSQL_HOST",
+								Value: mysqlPrimaryHost(cr),
+							},
+							secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+						}, env...),
+						VolumeMounts: backupMounts,
+					}},
+					Containers:    []corev1.Container{finalize},
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Volumes:       []corev1.Volume{backupStorageVolume(backup)},
+				},
+			},
+		},
+	}
+
+	if backupIncludesContent(backup) {
+		spec := &job.Spec.Template.Spec
+		spec.InitContainers = append(spec.InitContainers, corev1.Container{
+			Name:    "content-archive",
+			Image:   wordpressImage(cr),
+			Command: []string{"sh", "-c", contentArchiveScript},
+			Env:     env,
+			VolumeMounts: append([]corev1.VolumeMount{
+				{
+					Name:      "wordpress-persistent-storage",
+					MountPath: "/var/www/html",
+					ReadOnly:  true,
+				},
+			}, backupMounts...),
+		})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: "wordpress-persistent-storage",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: wordpressPVCName(cr),
+					ReadOnly:  true,
+				},
+			},
+		})
+		// The content volume may only attach to the node WordPress runs on
+		spec.Affinity = &corev1.Affinity{
+			PodAffinity: &corev1.PodAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						LabelSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"app":  cr.Name,
+								"tier": "frontend",
+							},
+						},
+						TopologyKey: corev1.LabelHostname,
+					},
+				}},
+			},
+		}
+	}
+
+	applyImagePolicy(cr, &job.Spec.Template.Spec)
+	applyJobOverrides(cr, &job.Spec.Template.Spec)
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &job.Spec.Template.ObjectMeta)
+
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// backupsOf lists the backups of an instance, newest first. Backups that are
+// being deleted are left out.
+func (r *WordpressReconciler) backupsOf(cr *v1.Wordpress) ([]v1.WordpressBackup, error) {
+	list := &v1.WordpressBackupList{}
+	if err := r.Client.List(context.TODO(), list, client.InNamespace(cr.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var backups []v1.WordpressBackup
+	for _, b := range list.Items {
+		if b.Spec.WordpressRef.Name == cr.Name && b.DeletionTimestamp.IsZero() {
+			backups = append(backups, b)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreationTimestamp.Equal(&backups[j].CreationTimestamp) {
+			return backups[j].CreationTimestamp.Before(&backups[i].CreationTimestamp)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// backupFinished reports whether a backup has come to an end
+func backupFinished(b *v1.WordpressBackup) bool {
+	return b.Status.Phase == v1.BackupSucceeded || b.Status.Phase == v1.BackupFailed
+}
+
+// isScheduledBackup reports whether a backup was taken on schedule
+func isScheduledBackup(b *v1.WordpressBackup) bool {
+	return b.Labels[v1.ScheduledBackupLabel] == "true"
+}
+
+// ensureScheduledBackup creates a WordpressBackup when a scheduled time has
+// passed since the last scheduled backup, and returns when the next one is
+// due. Runs missed while the operator was down are made up for with a single
+// backup. A run is postponed while another backup of the instance is going on.
+func (r *WordpressReconciler) ensureScheduledBackup(cr *v1.Wordpress, backups []v1.WordpressBackup, method v1.BackupMethod, now time.Time) (time.Time, error) {
+	schedule, err := cron.ParseStandard(backupSchedule(cr))
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	last := cr.CreationTimestamp.Time
+	for i := range backups {
+		if isScheduledBackup(&backups[i]) {
+			if backups[i].CreationTimestamp.After(last) {
+				last = backups[i].CreationTimestamp.Time
+			}
+			break
+		}
+	}
+	due := schedule.Next(last)
+	if due.After(now) {
+		return due, nil
+	}
+	if restoreInProgress(cr) {
+		r.Log.Info("Postponing scheduled backup, the instance is being restored",
+			"Wordpress.Namespace", cr.Namespace, "Wordpress.Name", cr.Name)
+		return due, nil
+	}
+	for i := range backups {
+		if !backupFinished(&backups[i]) {
+			r.Log.Info("Postponing scheduled backup, another backup is running",
+				"WordpressBackup.Namespace", backups[i].Namespace, "WordpressBackup.Name", backups[i].Name)
+			return due, nil
+		}
+	}
+
+	includeContent := scheduledBackupsIncludeContent(cr)
+	backup := &v1.WordpressBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			// Named after the scheduled time, so a run is never taken twice
+			Name:      fmt.Sprintf("%s-%s", cr.Name, due.UTC().Format("20060102150405")),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				v1.BackupInstanceLabel:  cr.Name,
+				v1.ScheduledBackupLabel: "true",
+			},
+		},
+		Spec: v1.WordpressBackupSpec{
+			WordpressRef: corev1.LocalObjectReference{
+				Name: cr.Name,
+			},
+			Method:         method,
+			IncludeContent: &includeContent,
+		},
+	}
+	r.Log.Info("Creating scheduled backup", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+	if err := r.Client.Create(context.TODO(), backup); err != nil && !errors.IsAlreadyExists(err) {
+		return time.Time{}, err
+	}
+	return schedule.Next(now), nil
+}
+
+// pruneScheduledBackups deletes the scheduled backups that fall outside the
+// retention limits. A failed backup is deleted once a later one succeeded.
+// The WordpressBackup finalizer removes the backup sets and VolumeSnapshots.
+func (r *WordpressReconciler) pruneScheduledBackups(cr *v1.Wordpress, backups []v1.WordpressBackup, now time.Time) error {
+	retention := backupRetention(cr)
+
+	kept := 0
+	for i := range backups {
+		b := &backups[i]
+		if !isScheduledBackup(b) || !backupFinished(b) {
+			continue
+		}
+
+		var reason string
+		switch {
+		case b.Status.Phase == v1.BackupFailed:
+			if kept == 0 {
+				continue
+			}
+			reason = "a later backup succeeded"
+		case retention.Count != nil && int32(kept) >= *retention.Count:
+			reason = fmt.Sprintf("more than %d backups", *retention.Count)
+		case retention.MaxAge != nil && now.Sub(b.CreationTimestamp.Time) > retention.MaxAge.Duration:
+			reason = fmt.Sprintf("older than %s", retention.MaxAge.Duration)
+		default:
+			kept++
+			continue
+		}
+
+		r.Log.Info("Pruning backup, "+reason, "WordpressBackup.Namespace", b.Namespace, "WordpressBackup.Name", b.Name)
+		if err := r.Client.Delete(context.TODO(), b); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeBackups reports on the backups of the instance in the status. Only a
+// failure is remembered after its backup was pruned.
+func observeBackups(cr *v1.Wordpress, backups []v1.WordpressBackup, next *time.Time) {
+	status := &v1.BackupStatus{
+		Destination: backupDestination(cr),
+	}
+	if next != nil {
+		nextScheduleTime := metav1.NewTime(*next)
+		status.NextScheduleTime = &nextScheduleTime
+	}
+	if previous := cr.Status.Backup; previous != nil {
+		status.LastFailureTime = previous.LastFailureTime
+		status.LastFailureMessage = previous.LastFailureMessage
+	}
+
+	var lastFinished *v1.WordpressBackup
+	for i := range backups {
+		b := &backups[i]
+		if isScheduledBackup(b) && status.LastScheduleTime == nil {
+			status.LastScheduleTime = b.CreationTimestamp.DeepCopy()
+		}
+		switch b.Status.Phase {
+		case v1.BackupSucceeded:
+			if status.LastSuccessfulTime == nil {
+				status.LastSuccessfulTime = b.Status.CompletionTime
+				status.LastBackup = b.Name
+			}
+		case v1.BackupFailed:
+			if b.Status.CompletionTime != nil &&
+				(status.LastFailureTime == nil || status.LastFailureTime.Before(b.Status.CompletionTime)) {
+				status.LastFailureTime = b.Status.CompletionTime
+				status.LastFailureMessage = fmt.Sprintf("Backup %s failed: %s", b.Name, b.Status.Message)
+			}
+		default:
+			continue
+		}
+		if lastFinished == nil {
+			lastFinished = b
+		}
+	}
+	cr.Status.Backup = status
+
+	switch {
+	case lastFinished == nil:
+		meta.RemoveStatusCondition(&cr.Status.Conditions, v1.ConditionBackupSucceeded)
+	case lastFinished.Status.Phase == v1.BackupSucceeded:
+		setCondition(cr, v1.ConditionBackupSucceeded, metav1.ConditionTrue, "BackupSucceeded",
+			fmt.Sprintf("Backup %s was written to %s", lastFinished.Name, lastFinished.Status.Location))
+	default:
+		setCondition(cr, v1.ConditionBackupSucceeded, metav1.ConditionFalse, "BackupFailed", status.LastFailureMessage)
+	}
+}
+
+// wordpressForBackup maps a backup to the instance it was taken of
+func wordpressForBackup(_ context.Context, obj client.Object) []reconcile.Request {
+	backup, ok := obj.(*v1.WordpressBackup)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Name: backup.Spec.WordpressRef.Name, Namespace: backup.Namespace},
+	}}
+}
@@ -2,17 +2,134 @@ package controller
 
 import (
 	"context"
+	"fmt"
 
 	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// checkOwnership refuses to take over an object that exists under the
+// desired name but is not controlled by this instance, for example one that
+// belongs to another Wordpress CR in the same namespace
+func checkOwnership(instance *v1.Wordpress, found metav1.Object, kind string) error {
+	if metav1.IsControlledBy(found, instance) {
+		return nil
+	}
+	return fmt.Errorf("%s %s/%s already exists and is not managed by Wordpress %s",
+		kind, found.GetNamespace(), found.GetName(), instance.Name)
+}
+
+// podTemplateDrifted reports whether a live pod template differs from the
+// desired one. DeepDerivative alone does not notice containers or volumes
+// that were dropped from the desired template, so the counts are compared too.
+// Placement, resources, pull secrets and the lists of the containers are
+// compared as a whole, the API server does not default them and removing an
+// entry must be rolled out.
+func podTemplateDrifted(desired, found *corev1.PodTemplateSpec) bool {
+	return len(desired.Spec.InitContainers) != len(found.Spec.InitContainers) ||
+		len(desired.Spec.Containers) != len(found.Spec.Containers) ||
+		len(desired.Spec.Volumes) != len(found.Spec.Volumes) ||
+		containersDrifted(desired.Spec.InitContainers, found.Spec.InitContainers) ||
+		containersDrifted(desired.Spec.Containers, found.Spec.Containers) ||
+		!equality.Semantic.DeepEqual(desired.Spec.ImagePullSecrets, found.Spec.ImagePullSecrets) ||
+		!equality.Semantic.DeepEqual(desired.Spec.NodeSelector, found.Spec.NodeSelector) ||
+		!equality.Semantic.DeepEqual(desired.Spec.Tolerations, found.Spec.Tolerations) ||
+		!equality.Semantic.DeepEqual(desired.Spec.Affinity, found.Spec.Affinity) ||
+		!equality.Semantic.DeepEqual(desired.Spec.TopologySpreadConstraints, found.Spec.TopologySpreadConstraints) ||
+		desired.Spec.PriorityClassName != found.Spec.PriorityClassName ||
+		!equality.Semantic.DeepDerivative(*desired, *found)
+}
+
+// containersDrifted reports whether the resources, env vars, mounts, ports or
+// arguments of live containers differ from the desired ones. The containers are paired
+// by index, their counts must match. Ports are compared with the protocol the
+// API server defaults them to.
+func containersDrifted(desired, found []corev1.Container) bool {
+	for i := range desired {
+		if !equality.Semantic.DeepEqual(desired[i].Resources, found[i].Resources) ||
+			!equality.Semantic.DeepEqual(desired[i].Env, found[i].Env) ||
+			!equality.Semantic.DeepEqual(desired[i].VolumeMounts, found[i].VolumeMounts) ||
+			!equality.Semantic.DeepEqual(desired[i].Args, found[i].Args) ||
+			!equality.Semantic.DeepEqual(defaultedPorts(desired[i].Ports), defaultedPorts(found[i].Ports)) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultedPorts returns the ports with the protocol filled in
+func defaultedPorts(ports []corev1.ContainerPort) []corev1.ContainerPort {
+	defaulted := make([]corev1.ContainerPort, len(ports))
+	for i, port := range ports {
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		defaulted[i] = port
+	}
+	return defaulted
+}
+
+// volumeClaimTemplatesResized reports whether the storage requested by the
+// volume claim templates of a live StatefulSet differs from the desired one
+func volumeClaimTemplatesResized(desired, found *appsv1.StatefulSet) bool {
+	if len(desired.Spec.VolumeClaimTemplates) != len(found.Spec.VolumeClaimTemplates) {
+		return false
+	}
+	for i := range desired.Spec.VolumeClaimTemplates {
+		want := desired.Spec.VolumeClaimTemplates[i].Spec.Resources.Requests[corev1.ResourceStorage]
+		have := found.Spec.VolumeClaimTemplates[i].Spec.Resources.Requests[corev1.ResourceStorage]
+		if want.Cmp(have) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeLabels adds the desired labels to the object's labels and reports
+// whether anything had to be changed.
+func mergeLabels(meta *metav1.ObjectMeta, desired map[string]string) bool {
+	changed := false
+	for k, v := range desired {
+		if meta.Labels[k] == v {
+			continue
+		}
+		if meta.Labels == nil {
+			meta.Labels = map[string]string{}
+		}
+		meta.Labels[k] = v
+		changed = true
+	}
+	return changed
+}
+
+// mergeAnnotations adds the desired annotations to the object's annotations
+// and reports whether anything had to be changed. Annotations set by others,
+// cloud providers or cert-manager for example, are left alone.
+func mergeAnnotations(meta *metav1.ObjectMeta, desired map[string]string) bool {
+	changed := false
+	for k, v := range desired {
+		if value, ok := meta.Annotations[k]; ok && value == v {
+			continue
+		}
+		if meta.Annotations == nil {
+			meta.Annotations = map[string]string{}
+		}
+		meta.Annotations[k] = v
+		changed = true
+	}
+	return changed
+}
+
 func (r *WordpressReconciler) ensureDeployment(_ reconcile.Request,
 	instance *v1.Wordpress,
 	dep *appsv1.Deployment,
@@ -45,10 +162,106 @@ func (r *WordpressReconciler) ensureDeployment(_ reconcile.Request,
 		return &ctrl.Result{}, err
 	}
 
+	if err := checkOwnership(instance, found, "Deployment"); err != nil {
+		r.Log.Error(err, "Refusing to adopt Deployment")
+		return &ctrl.Result{}, err
+	}
+
+	// The Deployment exists, bring it back in line with the desired state.
+	// DeepDerivative ignores fields we leave unset so that values defaulted
+	// by the API server do not count as drift.
+	labelsChanged := mergeLabels(&found.ObjectMeta, dep.Labels)
+	if !labelsChanged &&
+		equality.Semantic.DeepDerivative(dep.Spec.Replicas, found.Spec.Replicas) &&
+		!podTemplateDrifted(&dep.Spec.Template, &found.Spec.Template) {
+		return nil, nil
+	}
+
+	// Replicas left unset belong to a HorizontalPodAutoscaler
+	if dep.Spec.Replicas != nil {
+		found.Spec.Replicas = dep.Spec.Replicas
+	}
+	found.Spec.Template = dep.Spec.Template
+
+	r.Log.Info("Updating Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update Deployment", "Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
 	return nil, nil
 
 }
 
+func (r *WordpressReconciler) ensureStatefulSet(_ reconcile.Request,
+	instance *v1.Wordpress,
+	sts *appsv1.StatefulSet,
+) (*reconcile.Result, error) {
+
+	found := &appsv1.StatefulSet{}
+
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      sts.Name,
+		Namespace: instance.Namespace,
+	}, found)
+
+	if err != nil && errors.IsNotFound(err) {
+
+		// Create the StatefulSet
+		r.Log.Info("Creating a new StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+		err = r.Client.Create(context.TODO(), sts)
+
+		if err != nil {
+			// Creation failed
+			r.Log.Error(err, "Failed to create new StatefulSet", "StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+			return &reconcile.Result{}, err
+		}
+		// Creation was successful
+		return nil, nil
+
+	} else if err != nil {
+		// Error that isn't due to the StatefulSet not existing
+		r.Log.Error(err, "Failed to get StatefulSet")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "StatefulSet"); err != nil {
+		r.Log.Error(err, "Refusing to adopt StatefulSet")
+		return &ctrl.Result{}, err
+	}
+
+	// Volume claim templates are immutable. When the size in the template
+	// changed, delete the StatefulSet but leave its pods running and create
+	// it again on the next reconcile, so that new pods get the new size.
+	if volumeClaimTemplatesResized(sts, found) {
+		r.Log.Info("Replacing StatefulSet to resize its volume claim template", "StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+		if err := r.Client.Delete(context.TODO(), found, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !errors.IsNotFound(err) {
+			r.Log.Error(err, "Failed to delete StatefulSet", "StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+			return &ctrl.Result{}, err
+		}
+		return &ctrl.Result{Requeue: true}, nil
+	}
+
+	// The StatefulSet exists, bring it back in line with the desired state.
+	labelsChanged := mergeLabels(&found.ObjectMeta, sts.Labels)
+	if !labelsChanged &&
+		equality.Semantic.DeepDerivative(sts.Spec.Replicas, found.Spec.Replicas) &&
+		!podTemplateDrifted(&sts.Spec.Template, &found.Spec.Template) {
+		return nil, nil
+	}
+
+	found.Spec.Replicas = sts.Spec.Replicas
+	found.Spec.Template = sts.Spec.Template
+
+	r.Log.Info("Updating StatefulSet", "StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update StatefulSet", "StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
+	return nil, nil
+}
+
 func (r *WordpressReconciler) ensureService(_ reconcile.Request,
 	instance *v1.Wordpress,
 	s *corev1.Service,
@@ -78,6 +291,91 @@ func (r *WordpressReconciler) ensureService(_ reconcile.Request,
 		return &ctrl.Result{}, err
 	}
 
+	if err := checkOwnership(instance, found, "Service"); err != nil {
+		r.Log.Error(err, "Refusing to adopt Service")
+		return &ctrl.Result{}, err
+	}
+
+	// The Service exists, bring it back in line with the desired state.
+	// The cluster IP is immutable and allocated node ports are kept by the
+	// API server, so only the selector, ports and type are compared.
+	labelsChanged := mergeLabels(&found.ObjectMeta, s.Labels)
+	annotationsChanged := mergeAnnotations(&found.ObjectMeta, s.Annotations)
+	if !labelsChanged && !annotationsChanged &&
+		equality.Semantic.DeepEqual(s.Spec.Selector, found.Spec.Selector) &&
+		equality.Semantic.DeepDerivative(s.Spec.Ports, found.Spec.Ports) &&
+		(s.Spec.Type == "" || s.Spec.Type == found.Spec.Type) {
+		return nil, nil
+	}
+
+	found.Spec.Selector = s.Spec.Selector
+	found.Spec.Ports = s.Spec.Ports
+	if s.Spec.Type != "" {
+		found.Spec.Type = s.Spec.Type
+	}
+
+	r.Log.Info("Updating Service", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update Service", "Service.Namespace", found.Namespace, "Service.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
+	return nil, nil
+}
+
+func (r *WordpressReconciler) ensureIngress(_ reconcile.Request,
+	instance *v1.Wordpress,
+	ing *networkingv1.Ingress,
+) (*reconcile.Result, error) {
+	found := &networkingv1.Ingress{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      ing.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && errors.IsNotFound(err) {
+
+		// Create the Ingress
+		r.Log.Info("Creating a new Ingress", "Ingress.Namespace", ing.Namespace, "Ingress.Name", ing.Name)
+		err = r.Client.Create(context.TODO(), ing)
+
+		if err != nil {
+			// Creation failed
+			r.Log.Error(err, "Failed to create new Ingress", "Ingress.Namespace", ing.Namespace, "Ingress.Name", ing.Name)
+			return &ctrl.Result{}, err
+		}
+		// Creation was successful
+		return nil, nil
+
+	} else if err != nil {
+		// Error that isn't due to the Ingress not existing
+		r.Log.Error(err, "Failed to get Ingress")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "Ingress"); err != nil {
+		r.Log.Error(err, "Refusing to adopt Ingress")
+		return &ctrl.Result{}, err
+	}
+
+	// The Ingress exists, bring it back in line with the desired state. The
+	// class may have been defaulted by the API server, DeepDerivative does
+	// not count it as drift.
+	labelsChanged := mergeLabels(&found.ObjectMeta, ing.Labels)
+	annotationsChanged := mergeAnnotations(&found.ObjectMeta, ing.Annotations)
+	if !labelsChanged && !annotationsChanged &&
+		len(ing.Spec.TLS) == len(found.Spec.TLS) &&
+		equality.Semantic.DeepDerivative(ing.Spec, found.Spec) {
+		return nil, nil
+	}
+
+	found.Spec = ing.Spec
+
+	r.Log.Info("Updating Ingress", "Ingress.Namespace", found.Namespace, "Ingress.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update Ingress", "Ingress.Namespace", found.Namespace, "Ingress.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
 	return nil, nil
 }
 
@@ -112,43 +410,82 @@ func (r *WordpressReconciler) ensurePVC(_ reconcile.Request,
 		return &ctrl.Result{}, err
 	}
 
+	adopted, err := r.adoptRetained(instance, found)
+	if err != nil {
+		return &ctrl.Result{}, err
+	}
+	if err := checkOwnership(instance, found, "PVC"); err != nil {
+		r.Log.Error(err, "Refusing to adopt PVC")
+		return &ctrl.Result{}, err
+	}
+
+	// The PVC exists. Its spec is immutable apart from the storage request,
+	// so only the labels are brought back in line here.
+	labelsChanged := mergeLabels(&found.ObjectMeta, s.Labels)
+	if !labelsChanged && !adopted {
+		return nil, nil
+	}
+
+	r.Log.Info("Updating PVC", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update PVC", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
 	return nil, nil
 
 }
 
-func (r *WordpressReconciler) ensureCronJob(_ reconcile.Request,
+func (r *WordpressReconciler) ensureJob(_ reconcile.Request,
 	instance *v1.Wordpress,
-	cj *batchv1.CronJob,
+	job *batchv1.Job,
 ) (*reconcile.Result, error) {
 
-	found := &batchv1.CronJob{}
+	found := &batchv1.Job{}
 
 	err := r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      cj.Name,
+		Name:      job.Name,
 		Namespace: instance.Namespace,
 	}, found)
 
 	if err != nil && errors.IsNotFound(err) {
 
-		// Create the CronJob
-		r.Log.Info("Creating a new CronJob", "CronJob.Namespace", cj.Namespace, "CronJob.Name", cj.Name)
-		err = r.Client.Create(context.TODO(), cj)
+		// Create the Job
+		r.Log.Info("Creating a new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		err = r.Client.Create(context.TODO(), job)
 
 		if err != nil {
 			// Creation failed
-			r.Log.Error(err, "Failed to create new CronJob", "CronJob.Namespace", cj.Namespace, "CronJob.Name", cj.Name)
+			r.Log.Error(err, "Failed to create new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
 			return &reconcile.Result{}, err
 		}
 		// Creation was successful
 		return nil, nil
 
 	} else if err != nil {
-		// Error that isn't due to the CronJob not existing
-		r.Log.Error(err, "Failed to get CronJob")
+		// Error that isn't due to the Job not existing
+		r.Log.Error(err, "Failed to get Job")
 		return &ctrl.Result{}, err
 	}
 
-	return nil, nil
+	if err := checkOwnership(instance, found, "Job"); err != nil {
+		r.Log.Error(err, "Refusing to adopt Job")
+		return &ctrl.Result{}, err
+	}
+
+	// The pod template of a Job cannot be changed. Replace a drifted Job
+	// and create it again on the next reconcile.
+	if !podTemplateDrifted(&job.Spec.Template, &found.Spec.Template) {
+		return nil, nil
+	}
+
+	r.Log.Info("Replacing Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+	if err := r.Client.Delete(context.TODO(), found, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		r.Log.Error(err, "Failed to delete Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
+	return &ctrl.Result{Requeue: true}, nil
 }
 
 func (r *WordpressReconciler) ensureBackupPVC(_ reconcile.Request,
@@ -182,6 +519,28 @@ func (r *WordpressReconciler) ensureBackupPVC(_ reconcile.Request,
 		return &ctrl.Result{}, err
 	}
 
+	adopted, err := r.adoptRetained(instance, found)
+	if err != nil {
+		return &ctrl.Result{}, err
+	}
+	if err := checkOwnership(instance, found, "PVC"); err != nil {
+		r.Log.Error(err, "Refusing to adopt PVC")
+		return &ctrl.Result{}, err
+	}
+
+	// The PVC exists. Its spec is immutable apart from the storage request,
+	// so only the labels are brought back in line here.
+	labelsChanged := mergeLabels(&found.ObjectMeta, pvc.Labels)
+	if !labelsChanged && !adopted {
+		return nil, nil
+	}
+
+	r.Log.Info("Updating Backup PVC", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update Backup PVC", "PVC.Namespace", found.Namespace, "PVC.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
 	return nil, nil
 }
 
@@ -216,5 +575,38 @@ func (r *WordpressReconciler) ensureMysqlSecret(_ reconcile.Request,
 		return &ctrl.Result{}, err
 	}
 
+	adopted, err := r.adoptRetained(instance, found)
+	if err != nil {
+		return &ctrl.Result{}, err
+	}
+	if err := checkOwnership(instance, found, "Secret"); err != nil {
+		r.Log.Error(err, "Refusing to adopt Secret")
+		return &ctrl.Result{}, err
+	}
+
+	// The Secret exists. Restore any key that went missing but never
+	// overwrite a value that is already there, since the database was
+	// initialised with it.
+	changed := mergeLabels(&found.ObjectMeta, secret.Labels) || adopted
+	for k, v := range secret.Data {
+		if _, ok := found.Data[k]; ok {
+			continue
+		}
+		if found.Data == nil {
+			found.Data = map[string][]byte{}
+		}
+		found.Data[k] = v
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+
+	r.Log.Info("Updating MySQL Secret", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update MySQL Secret", "Secret.Namespace", found.Namespace, "Secret.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+
 	return nil, nil
 }
@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"strings"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// statelessConfigScript is added to wp-config.php of Stateless instances.
+// Files changed from the dashboard would only land in one pod and be gone
+// with it, so changing them is turned off. The uploads plugin of the image
+// reads the S3_UPLOADS_* constants.
+const statelessConfigScript = `define('DISALLOW_FILE_MODS', true);
+define('S3_UPLOADS_BUCKET', getenv('S3_UPLOADS_BUCKET'));
+define('S3_UPLOADS_REGION', getenv('S3_UPLOADS_REGION'));
+define('S3_UPLOADS_KEY', getenv('S3_UPLOADS_KEY'));
+define('S3_UPLOADS_SECRET', getenv('S3_UPLOADS_SECRET'));
+if (getenv('S3_UPLOADS_ENDPOINT')) {
+	define('S3_UPLOADS_ENDPOINT', getenv('S3_UPLOADS_ENDPOINT'));
+}
+`
+
+// contentMode returns where the files of the site live
+func contentMode(cr *v1.Wordpress) v1.ContentMode {
+	if cr.Spec.Content == nil || cr.Spec.Content.Mode == "" {
+		return v1.ContentVolume
+	}
+	return cr.Spec.Content.Mode
+}
+
+// hasContentVolume reports whether the files of the site are kept on a
+// volume, which backups archive and restores unpack into
+func hasContentVolume(cr *v1.Wordpress) bool {
+	return contentMode(cr) != v1.ContentStateless
+}
+
+// uploadsBucket returns the bucket and prefix uploads are kept under, in the
+// bucket/prefix form the uploads plugin expects
+func uploadsBucket(cr *v1.Wordpress, uploads *v1.UploadsSpec) string {
+	prefix := uploads.Prefix
+	if prefix == "" {
+		prefix = cr.Namespace + "/" + cr.Name + "/uploads"
+	}
+	return uploads.Bucket + "/" + strings.Trim(prefix, "/")
+}
+
+// contentEnv returns the environment of the WordPress container that depends
+// on where the content lives
+func contentEnv(cr *v1.Wordpress) []corev1.EnvVar {
+	if contentMode(cr) != v1.ContentStateless || cr.Spec.Content.Uploads == nil {
+		return nil
+	}
+	uploads := cr.Spec.Content.Uploads
+	env := []corev1.EnvVar{
+		{Name: "S3_UPLOADS_BUCKET", Value: uploadsBucket(cr, uploads)},
+		{Name: "S3_UPLOADS_REGION", Value: uploads.Region},
+		secretEnv("S3_UPLOADS_KEY", secretKey(uploads.CredentialsSecretRef.Name, "AWS_ACCESS_KEY_ID")),
+		secretEnv("S3_UPLOADS_SECRET", secretKey(uploads.CredentialsSecretRef.Name, "AWS_SECRET_ACCESS_KEY")),
+	}
+	if uploads.Endpoint != "" {
+		env = append(env, corev1.EnvVar{Name: "S3_UPLOADS_ENDPOINT", Value: uploads.Endpoint})
+	}
+	return env
+}
+
+// contentVolumeSource returns what /var/www/html is mounted from. Stateless
+// pods unpack WordPress from their image into a scratch directory.
+func contentVolumeSource(cr *v1.Wordpress) corev1.VolumeSource {
+	if !hasContentVolume(cr) {
+		return corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}
+	}
+	return corev1.VolumeSource{
+		PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: wordpressPVCName(cr),
+		},
+	}
+}
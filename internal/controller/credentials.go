@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// mysqlRootPasswordKey is the key of the root password in the operator
+// managed MySQL Secret
+const mysqlRootPasswordKey = "password"
+
+// Keys of the operator managed Secret holding the WordPress database user
+const (
+	wordpressDBUsernameKey = "username"
+	wordpressDBPasswordKey = "password"
+)
+
+// wordpressDatabaseName is the database WordPress keeps its tables in. It is
+// the database the WordPress image created back when it connected as root.
+const wordpressDatabaseName = "wordpress"
+
+// dbCredentials tells where each database credential is read from.
+//
+// The root password comes from, in order of precedence:
+//  1. spec.credentialsSecretRef
+//  2. spec.sqlRootPassword, copied into the operator managed Secret
+//  3. a random password generated into the operator managed Secret
+//
+// The WordPress user comes from spec.credentialsSecretRef when that Secret
+// has it, and otherwise from a Secret generated by the operator. Root is only
+// used to administer the database and take backups.
+type dbCredentials struct {
+	rootPassword corev1.SecretKeySelector
+	username     corev1.SecretKeySelector
+	password     corev1.SecretKeySelector
+}
+
+// databaseCredentials resolves the credentials of the instance. It fails when
+// the referenced Secret is missing or lacks the root password, so that nothing
+// is started with credentials the user did not intend.
+func (r *WordpressReconciler) databaseCredentials(cr *v1.Wordpress) (*dbCredentials, error) {
+	ref := cr.Spec.CredentialsSecretRef
+	if ref == nil {
+		return &dbCredentials{
+			rootPassword: secretKey(mysqlSecretName(cr), mysqlRootPasswordKey),
+			username:     secretKey(wordpressDBSecretName(cr), wordpressDBUsernameKey),
+			password:     secretKey(wordpressDBSecretName(cr), wordpressDBPasswordKey),
+		}, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: cr.Namespace,
+	}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("credentials Secret %q not found", ref.Name)
+		}
+		return nil, err
+	}
+
+	rootPasswordKey := stringOr(ref.RootPasswordKey, v1.DefaultRootPasswordKey)
+	if len(secret.Data[rootPasswordKey]) == 0 {
+		return nil, fmt.Errorf("credentials Secret %q has no %q key", ref.Name, rootPasswordKey)
+	}
+	creds := &dbCredentials{
+		rootPassword: secretKey(ref.Name, rootPasswordKey),
+		username:     secretKey(wordpressDBSecretName(cr), wordpressDBUsernameKey),
+		password:     secretKey(wordpressDBSecretName(cr), wordpressDBPasswordKey),
+	}
+
+	// The WordPress user is optional, but half of it is a mistake
+	usernameKey := stringOr(ref.UsernameKey, v1.DefaultUsernameKey)
+	passwordKey := stringOr(ref.PasswordKey, v1.DefaultPasswordKey)
+	_, hasUsername := secret.Data[usernameKey]
+	_, hasPassword := secret.Data[passwordKey]
+	switch {
+	case hasUsername && hasPassword:
+		creds.username, creds.password = secretKey(ref.Name, usernameKey), secretKey(ref.Name, passwordKey)
+	case hasUsername || hasPassword:
+		return nil, fmt.Errorf("credentials Secret %q must have both %q and %q keys, or neither",
+			ref.Name, usernameKey, passwordKey)
+	}
+
+	return creds, nil
+}
+
+// generatesWordpressUser reports whether the operator has to generate the
+// WordPress database user into a Secret of its own
+func (c *dbCredentials) generatesWordpressUser(cr *v1.Wordpress) bool {
+	return c.username.Name == wordpressDBSecretName(cr)
+}
+
+// wordpressDatabaseEnv returns the environment WordPress connects to the
+// database with
+func (c *dbCredentials) wordpressDatabaseEnv() []corev1.EnvVar {
+	return []corev1.EnvVar{
+		secretEnv("WORDPRESS_DB_USER", c.username),
+		secretEnv("WORDPRESS_DB_PASSWORD", c.password),
+		{Name: "WORDPRESS_DB_NAME", Value: wordpressDatabaseName},
+	}
+}
+
+// secretForWordpressDatabase returns the Secret holding the generated
+// WordPress database user. Values of an existing Secret are never replaced,
+// see ensureMysqlSecret.
+func (r *WordpressReconciler) secretForWordpressDatabase(cr *v1.Wordpress) (*corev1.Secret, error) {
+	password, err := generateRandomPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wordpressDBSecretName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		Data: map[string][]byte{
+			wordpressDBUsernameKey: []byte("wordpress"),
+			wordpressDBPasswordKey: []byte(password),
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// mysqlUserBootstrapScript creates the WordPress database and user, or
+// brings the password of an existing user in line with the Secret. Every
+// statement is idempotent so the Job can be run again at any time.
+const mysqlUserBootstrapScript = `set -e
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+until mysqladmin ping -h "$MYSQL_HOST" -u root --silent; do sleep 2; done
+# Quote the values for use inside SQL string literals, backslashes first
+quote() { printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e "s/'/''/g"; }
+user=$(quote "$WORDPRESS_DB_USER")
+password=$(quote "$WORDPRESS_DB_PASSWORD")
+mysql -h "$MYSQL_HOST" -u root <<EOF
+CREATE DATABASE IF NOT EXISTS $WORDPRESS_DB_NAME;
+CREATE USER IF NOT EXISTS '$user'@'%' IDENTIFIED BY '$password';
+ALTER USER '$user'@'%' IDENTIFIED BY '$password';
+GRANT ALL PRIVILEGES ON $WORDPRESS_DB_NAME.* TO '$user'@'%';
+EOF
+`
+
+// jobForMysqlUserBootstrap creates a Job that sets up the WordPress database
+// user on the primary. Databases initialised before WordPress stopped
+// connecting as root get the user the same way as new ones.
+func (r *WordpressReconciler) jobForMysqlUserBootstrap(cr *v1.Wordpress) (*batchv1.Job, error) {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	backoffLimit := int32(6)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlUserBootstrapJobName(cr),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    "bootstrap-user",
+						Image:   mysqlImage(cr),
+						Command: []string{"bash", "-c", mysqlUserBootstrapScript},
+						Env: append([]corev1.EnvVar{
+							{
+								Name:  "MYSQL_HOST",
+								Value: mysqlPrimaryHost(cr),
+							},
+							secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+						}, creds.wordpressDatabaseEnv()...),
+					}},
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+				},
+			},
+		},
+	}
+
+	applyImagePolicy(cr, &job.Spec.Template.Spec)
+	applyJobOverrides(cr, &job.Spec.Template.Spec)
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &job.Spec.Template.ObjectMeta)
+
+	controllerutil.SetControllerReference(cr, job, r.Scheme)
+	return job, nil
+}
+
+// wordpressesForSecret maps a Secret to the instances whose credentials it holds
+func (r *WordpressReconciler) wordpressesForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &v1.WordpressList{}
+	if err := r.Client.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "Failed to list Wordpress instances", "Secret.Namespace", obj.GetNamespace(), "Secret.Name", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, wp := range list.Items {
+		if ref := wp.Spec.CredentialsSecretRef; ref != nil && ref.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: wp.Name, Namespace: wp.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+func secretKey(name, key string) corev1.SecretKeySelector {
+	return corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{
+			Name: name,
+		},
+		Key: key,
+	}
+}
+
+func secretEnv(name string, key corev1.SecretKeySelector) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &key,
+		},
+	}
+}
+
+func stringOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
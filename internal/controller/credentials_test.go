@@ -0,0 +1,50 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"os/exec"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL quoting", func() {
+	// quoteFunction returns the quote() definition of a script
+	quoteFunction := func(script string) string {
+		for _, line := range strings.Split(script, "\n") {
+			if strings.HasPrefix(line, "quote()") {
+				return line
+			}
+		}
+		Fail("the script does not define quote()")
+		return ""
+	}
+
+	for name, script := range map[string]string{
+		"user bootstrap":      mysqlUserBootstrapScript,
+		"credential rotation": mysqlCredentialRotationScript,
+	} {
+		script := script
+		It("should keep backslashes and quotes inside the literal in the "+name+" script", func() {
+			out, err := exec.Command("bash", "-c", quoteFunction(script)+`; quote "$1"`, "quote", `it's\`).Output()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(out)).To(Equal(`it''s\\`))
+		})
+	}
+})
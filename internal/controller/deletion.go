@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// instanceFinalizer holds the objects of an instance back from the garbage
+// collector until its deletion policy has been carried out
+const instanceFinalizer = "wordpress.gopkg.blogpost.com/deletion-policy"
+
+// deletionPolicy returns the deletion policy of the instance
+func deletionPolicy(cr *v1.Wordpress) v1.DeletionPolicy {
+	if cr.Spec.DeletionPolicy == "" {
+		return v1.DeletionPolicyRetain
+	}
+	return cr.Spec.DeletionPolicy
+}
+
+// finalizeInstance carries out the deletion policy of an instance that is
+// being deleted and then lets it go. The workloads keep running until the
+// finalizer is removed, the final backup of the Snapshot policy needs them.
+// Foreground deletion removes them first, which fails the final backup.
+func (r *WordpressReconciler) finalizeInstance(ctx context.Context, cr *v1.Wordpress) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(cr, instanceFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	originalStatus := cr.Status.DeepCopy()
+	cr.Status.Phase = v1.PhaseDeleting
+
+	switch deletionPolicy(cr) {
+	case v1.DeletionPolicyRetain:
+		if err := r.retainData(ctx, cr); err != nil {
+			return ctrl.Result{}, err
+		}
+
+	case v1.DeletionPolicySnapshot:
+		backup, err := r.ensureFinalBackup(ctx, cr)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if backup.Status.Phase == v1.BackupFailed {
+			setCondition(cr, v1.ConditionReady, metav1.ConditionFalse, "FinalBackupFailed",
+				fmt.Sprintf("Final backup %s failed: %s. Change spec.deletionPolicy to delete the instance without it",
+					backup.Name, backup.Status.Message))
+			return ctrl.Result{}, r.updateStatus(ctx, cr, originalStatus)
+		}
+		if backup.Status.Phase != v1.BackupSucceeded {
+			// The backup wakes the instance up when it finishes
+			setCondition(cr, v1.ConditionReady, metav1.ConditionFalse, "FinalBackupPending",
+				fmt.Sprintf("Waiting for final backup %s to finish", backup.Name))
+			return ctrl.Result{}, r.updateStatus(ctx, cr, originalStatus)
+		}
+
+		// A dump on the backup volume goes with the volume
+		if !isSnapshotBackup(backup) && backup.Status.S3 == nil {
+			if err := r.retain(ctx, cr, &corev1.PersistentVolumeClaim{}, "PVC", backupPVCName(cr)); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := r.deleteMysqlVolumes(ctx, cr); err != nil {
+			return ctrl.Result{}, err
+		}
+
+	default:
+		if err := r.deleteMysqlVolumes(ctx, cr); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	r.Log.Info("Deletion policy carried out", "Wordpress.Namespace", cr.Namespace, "Wordpress.Name", cr.Name,
+		"DeletionPolicy", deletionPolicy(cr))
+	controllerutil.RemoveFinalizer(cr, instanceFinalizer)
+	return ctrl.Result{}, r.Client.Update(ctx, cr)
+}
+
+// ensureFinalBackup creates the backup the Snapshot deletion policy takes
+// before letting the instance go, and returns it. It is taken like the
+// scheduled backups but always includes the content, if the instance keeps
+// any, and it is never pruned.
+func (r *WordpressReconciler) ensureFinalBackup(ctx context.Context, cr *v1.Wordpress) (*v1.WordpressBackup, error) {
+	backup := &v1.WordpressBackup{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: finalBackupName(cr), Namespace: cr.Namespace}, backup)
+	if err == nil {
+		return backup, nil
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	method, err := r.scheduledBackupMethod(cr)
+	if err != nil {
+		return nil, err
+	}
+	includeContent := hasContentVolume(cr)
+	backup = &v1.WordpressBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      finalBackupName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				v1.BackupInstanceLabel: cr.Name,
+			},
+		},
+		Spec: v1.WordpressBackupSpec{
+			WordpressRef: corev1.LocalObjectReference{
+				Name: cr.Name,
+			},
+			Method:         method,
+			IncludeContent: &includeContent,
+		},
+	}
+	r.Log.Info("Creating final backup", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+	if err := r.Client.Create(ctx, backup); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// retainData releases the volumes and the generated credentials Secrets of
+// an instance, so that the garbage collector leaves them alone. The volumes
+// the MySQL StatefulSet created are never owned by the instance.
+func (r *WordpressReconciler) retainData(ctx context.Context, cr *v1.Wordpress) error {
+	for _, name := range []string{wordpressPVCName(cr), mysqlPVCName(cr), backupPVCName(cr)} {
+		if err := r.retain(ctx, cr, &corev1.PersistentVolumeClaim{}, "PVC", name); err != nil {
+			return err
+		}
+	}
+	// The database keeps the passwords it was initialised with, a new
+	// instance taking over the volumes needs them
+	for _, name := range []string{mysqlSecretName(cr), wordpressDBSecretName(cr)} {
+		if err := r.retain(ctx, cr, &corev1.Secret{}, "Secret", name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retain drops the owner reference of the instance from an object it
+// controls and marks the object with RetainedFromAnnotation
+func (r *WordpressReconciler) retain(ctx context.Context, cr *v1.Wordpress, obj client.Object, kind, name string) error {
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, cr) {
+		return nil
+	}
+
+	var refs []metav1.OwnerReference
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID != cr.UID {
+			refs = append(refs, ref)
+		}
+	}
+	obj.SetOwnerReferences(refs)
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[v1.RetainedFromAnnotation] = cr.Name
+	obj.SetAnnotations(annotations)
+
+	r.Log.Info("Retaining "+kind, kind+".Namespace", cr.Namespace, kind+".Name", name)
+	return r.Client.Update(ctx, obj)
+}
+
+// adoptRetained takes back an object that an earlier instance of the same
+// name left behind under the Retain deletion policy, and reports whether it
+// did. The caller saves the object.
+func (r *WordpressReconciler) adoptRetained(instance *v1.Wordpress, found client.Object) (bool, error) {
+	if found.GetAnnotations()[v1.RetainedFromAnnotation] != instance.Name || metav1.GetControllerOf(found) != nil {
+		return false, nil
+	}
+	if err := controllerutil.SetControllerReference(instance, found, r.Scheme); err != nil {
+		return false, err
+	}
+	annotations := found.GetAnnotations()
+	delete(annotations, v1.RetainedFromAnnotation)
+	found.SetAnnotations(annotations)
+	r.Log.Info("Adopting retained object", "Object.Namespace", found.GetNamespace(), "Object.Name", found.GetName())
+	return true, nil
+}
+
+// deleteMysqlVolumes deletes the volumes the MySQL StatefulSet created. They
+// are not owned by the instance and would otherwise outlive it.
+func (r *WordpressReconciler) deleteMysqlVolumes(ctx context.Context, cr *v1.Wordpress) error {
+	pvcs, err := r.mysqlVolumes(ctx, cr)
+	if err != nil {
+		return err
+	}
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		r.Log.Info("Deleting MySQL PVC", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name)
+		if err := r.Client.Delete(ctx, pvc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
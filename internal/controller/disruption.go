@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// wordpressReplicas returns the number of WordPress pods the spec asks for,
+// the lowest one when autoscaled
+func wordpressReplicas(cr *v1.Wordpress) int32 {
+	if isAutoscaled(cr) {
+		return minReplicas(cr)
+	}
+	if cr.Spec.Replicas != nil {
+		return *cr.Spec.Replicas
+	}
+	return 1
+}
+
+// mysqlReplicas returns the number of MySQL pods the spec asks for
+func mysqlReplicas(cr *v1.Wordpress) int32 {
+	if cr.Spec.MysqlReplicas != nil && *cr.Spec.MysqlReplicas > 0 {
+		return *cr.Spec.MysqlReplicas
+	}
+	return 1
+}
+
+// disruptionBudgetOverride returns the budget of the spec for a tier, if any
+func disruptionBudgetOverride(cr *v1.Wordpress, tier string) *v1.DisruptionBudget {
+	if cr.Spec.DisruptionBudgets == nil {
+		return nil
+	}
+	if tier == "mysql" {
+		return cr.Spec.DisruptionBudgets.Mysql
+	}
+	return cr.Spec.DisruptionBudgets.Wordpress
+}
+
+// Creates the PodDisruptionBudget of a tier, or returns nil when the tier
+// should have none. Without an override one pod out of several may be
+// evicted at a time, a single pod is left unprotected so that node drains
+// do not hang on it.
+func (r *WordpressReconciler) pdbForTier(cr *v1.Wordpress, tier, name string, replicas int32) *policyv1.PodDisruptionBudget {
+	budget := disruptionBudgetOverride(cr, tier)
+	if budget == nil {
+		if replicas < 2 {
+			return nil
+		}
+		maxUnavailable := intstr.FromInt(1)
+		budget = &v1.DisruptionBudget{MaxUnavailable: &maxUnavailable}
+	}
+
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable:   budget.MinAvailable,
+			MaxUnavailable: budget.MaxUnavailable,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":  cr.Name,
+					"tier": tier,
+				},
+			},
+		},
+	}
+
+	controllerutil.SetControllerReference(cr, pdb, r.Scheme)
+	return pdb
+}
+
+// ensureDisruptionBudget keeps the PodDisruptionBudget of a tier in step
+// with the replicas, and deletes it when the tier should have none
+func (r *WordpressReconciler) ensureDisruptionBudget(request reconcile.Request, instance *v1.Wordpress, tier, name string, replicas int32) (*reconcile.Result, error) {
+	pdb := r.pdbForTier(instance, tier, name, replicas)
+	if pdb == nil {
+		return nil, r.removeOwned(context.TODO(), instance, &policyv1.PodDisruptionBudget{}, "PodDisruptionBudget", name)
+	}
+	return r.ensurePDB(request, instance, pdb)
+}
+
+func (r *WordpressReconciler) ensurePDB(_ reconcile.Request,
+	instance *v1.Wordpress,
+	pdb *policyv1.PodDisruptionBudget,
+) (*reconcile.Result, error) {
+
+	found := &policyv1.PodDisruptionBudget{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      pdb.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new PodDisruptionBudget", "PodDisruptionBudget.Namespace", pdb.Namespace, "PodDisruptionBudget.Name", pdb.Name)
+		if err := r.Client.Create(context.TODO(), pdb); err != nil {
+			r.Log.Error(err, "Failed to create new PodDisruptionBudget", "PodDisruptionBudget.Namespace", pdb.Namespace, "PodDisruptionBudget.Name", pdb.Name)
+			return &reconcile.Result{}, err
+		}
+		return nil, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get PodDisruptionBudget")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "PodDisruptionBudget"); err != nil {
+		r.Log.Error(err, "Refusing to adopt PodDisruptionBudget")
+		return &ctrl.Result{}, err
+	}
+
+	labelsChanged := mergeLabels(&found.ObjectMeta, pdb.Labels)
+	if !labelsChanged &&
+		equality.Semantic.DeepEqual(pdb.Spec.MinAvailable, found.Spec.MinAvailable) &&
+		equality.Semantic.DeepEqual(pdb.Spec.MaxUnavailable, found.Spec.MaxUnavailable) &&
+		equality.Semantic.DeepEqual(pdb.Spec.Selector, found.Spec.Selector) {
+		return nil, nil
+	}
+
+	found.Spec.MinAvailable = pdb.Spec.MinAvailable
+	found.Spec.MaxUnavailable = pdb.Spec.MaxUnavailable
+	found.Spec.Selector = pdb.Spec.Selector
+	r.Log.Info("Updating PodDisruptionBudget", "PodDisruptionBudget.Namespace", found.Namespace, "PodDisruptionBudget.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update PodDisruptionBudget", "PodDisruptionBudget.Namespace", found.Namespace, "PodDisruptionBudget.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// nginxFPMScript configures nginx to serve /var/www/html and hand PHP
+// requests to the fpm container next to it
+const nginxFPMScript = `cat > /etc/nginx/conf.d/default.conf <<'EOF'
+server {
+    listen 80;
+    root /var/www/html;
+    index index.php;
+    client_max_body_size 64m;
+
+    location / {
+        try_files $uri $uri/ /index.php?$args;
+    }
+
+    location ~ \.php$ {
+        include fastcgi_params;
+        fastcgi_pass 127.0.0.1:9000;
+        fastcgi_param SCRIPT_FILENAME $document_root$fastcgi_script_name;
+    }
+}
+EOF
+exec nginx -g 'daemon off;'
+`
+
+// wordpressVariant returns the configured image variant, apache by default
+func wordpressVariant(cr *v1.Wordpress) v1.WordpressVariant {
+	if cr.Spec.Image != nil && cr.Spec.Image.Variant != "" {
+		return cr.Spec.Image.Variant
+	}
+	return v1.DefaultWordpressVariant
+}
+
+// wordpressImage returns the WordPress image reference
+func wordpressImage(cr *v1.Wordpress) string {
+	repository := v1.DefaultWordpressRepository
+	tag := v1.DefaultWordpressTag
+	if cr.Spec.Image != nil {
+		if cr.Spec.Image.Repository != "" {
+			repository = cr.Spec.Image.Repository
+		}
+		if cr.Spec.Image.Tag != "" {
+			tag = cr.Spec.Image.Tag
+		}
+	}
+	return fmt.Sprintf("%s:%s-%s", repository, tag, wordpressVariant(cr))
+}
+
+// webServerImage returns the nginx image used with the fpm variant
+func webServerImage(cr *v1.Wordpress) string {
+	if cr.Spec.Image != nil && cr.Spec.Image.WebServerImage != "" {
+		return cr.Spec.Image.WebServerImage
+	}
+	return v1.DefaultWebServerImage
+}
+
+// mysqlImage returns the MySQL server image. Legacy instances keep the image
+// they were installed with, MySQL does not start on the data of a newer one.
+func mysqlImage(cr *v1.Wordpress) string {
+	if cr.Spec.DatabaseImage != "" {
+		return cr.Spec.DatabaseImage
+	}
+	if usesLegacyNames(cr) {
+		return v1.LegacyDatabaseImage
+	}
+	return v1.DefaultDatabaseImage
+}
+
+// backupImage returns the image backup jobs run from, the server image
+// unless configured otherwise
+func backupImage(cr *v1.Wordpress) string {
+	if cr.Spec.BackupImage != "" {
+		return cr.Spec.BackupImage
+	}
+	return mysqlImage(cr)
+}
+
+// applyImagePolicy sets the pull secrets and the pull policy of the instance
+// on a pod spec built by the operator
+func applyImagePolicy(cr *v1.Wordpress, spec *corev1.PodSpec) {
+	spec.ImagePullSecrets = cr.Spec.ImagePullSecrets
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].ImagePullPolicy = cr.Spec.ImagePullPolicy
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].ImagePullPolicy = cr.Spec.ImagePullPolicy
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wordpressv1alpha1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+)
+
+var _ = Describe("Images", func() {
+	It("should default every image", func() {
+		cr := &wordpressv1alpha1.Wordpress{}
+		Expect(wordpressImage(cr)).To(Equal("wordpress:5.4-apache"))
+		Expect(wordpressVariant(cr)).To(Equal(wordpressv1alpha1.VariantApache))
+		Expect(webServerImage(cr)).To(Equal(wordpressv1alpha1.DefaultWebServerImage))
+		Expect(mysqlImage(cr)).To(Equal(wordpressv1alpha1.DefaultDatabaseImage))
+		Expect(backupImage(cr)).To(Equal(wordpressv1alpha1.DefaultDatabaseImage))
+	})
+
+	It("should build the WordPress image from the repository, tag and variant", func() {
+		cr := &wordpressv1alpha1.Wordpress{
+			Spec: wordpressv1alpha1.WordpressSpec{
+				Image: &wordpressv1alpha1.WordpressImage{
+					Repository:     "registry.example.com/wordpress",
+					Tag:            "6.5",
+					Variant:        wordpressv1alpha1.VariantFPM,
+					WebServerImage: "nginx:1.27",
+				},
+			},
+		}
+		Expect(wordpressImage(cr)).To(Equal("registry.example.com/wordpress:6.5-fpm"))
+		Expect(webServerImage(cr)).To(Equal("nginx:1.27"))
+
+		// Only the tag is set, the rest keeps the defaults
+		cr.Spec.Image = &wordpressv1alpha1.WordpressImage{Tag: "6.5"}
+		Expect(wordpressImage(cr)).To(Equal("wordpress:6.5-apache"))
+	})
+
+	It("should run backups from the database image unless told otherwise", func() {
+		cr := &wordpressv1alpha1.Wordpress{
+			Spec: wordpressv1alpha1.WordpressSpec{DatabaseImage: "mysql:8.4"},
+		}
+		Expect(mysqlImage(cr)).To(Equal("mysql:8.4"))
+		Expect(backupImage(cr)).To(Equal("mysql:8.4"))
+
+		cr.Spec.BackupImage = "registry.example.com/mysql-tools:1"
+		Expect(backupImage(cr)).To(Equal("registry.example.com/mysql-tools:1"))
+	})
+
+	It("should keep legacy instances on the image they were installed with", func() {
+		cr := &wordpressv1alpha1.Wordpress{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{wordpressv1alpha1.LegacyNamingAnnotation: "true"},
+			},
+		}
+		Expect(mysqlImage(cr)).To(Equal(wordpressv1alpha1.LegacyDatabaseImage))
+
+		cr.Spec.DatabaseImage = "mysql:8.4"
+		Expect(mysqlImage(cr)).To(Equal("mysql:8.4"))
+	})
+
+	It("should apply the pull policy and secrets to every container", func() {
+		cr := &wordpressv1alpha1.Wordpress{
+			Spec: wordpressv1alpha1.WordpressSpec{
+				ImagePullPolicy:  corev1.PullIfNotPresent,
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}},
+			},
+		}
+		spec := &corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "init"}},
+			Containers:     []corev1.Container{{Name: "main"}, {Name: "sidecar"}},
+		}
+		applyImagePolicy(cr, spec)
+		Expect(spec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry"}))
+		Expect(spec.InitContainers[0].ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		for _, container := range spec.Containers {
+			Expect(container.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		}
+	})
+})
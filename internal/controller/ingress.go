@@ -0,0 +1,269 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// HTTPRoutes are handled as unstructured objects, like VolumeSnapshots, so
+// that the operator runs on clusters without the Gateway API CRDs
+var httpRouteGVK = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1",
+	Kind:    "HTTPRoute",
+}
+
+// gatewayAPIUnavailable explains why an HTTPRoute cannot be created
+const gatewayAPIUnavailable = "the cluster does not support HTTPRoutes, the gateway.networking.k8s.io CRDs are not installed"
+
+// siteURLConfigScript is added to wp-config.php when the site has a public
+// URL. The URL is passed in the environment rather than written into the
+// PHP code. TLS is terminated in front of WordPress, which learns about it
+// from the X-Forwarded-Proto header.
+const siteURLConfigScript = `define('WP_HOME', getenv('WP_HOME'));
+define('WP_SITEURL', getenv('WP_HOME'));
+if (isset($_SERVER['HTTP_X_FORWARDED_PROTO']) && $_SERVER['HTTP_X_FORWARDED_PROTO'] === 'https') {
+	$_SERVER['HTTPS'] = 'on';
+}
+`
+
+// publicURL returns the URL the site is served on from outside the cluster,
+// or an empty string when it is only reachable through its Service
+func publicURL(cr *v1.Wordpress) string {
+	if ing := cr.Spec.Ingress; ing != nil && len(ing.Hosts) > 0 {
+		if ing.TLS != nil {
+			return "https://" + ing.Hosts[0]
+		}
+		return "http://" + ing.Hosts[0]
+	}
+	if gw := cr.Spec.Gateway; gw != nil && len(gw.Hostnames) > 0 {
+		if gw.HTTPS {
+			return "https://" + gw.Hostnames[0]
+		}
+		return "http://" + gw.Hostnames[0]
+	}
+	return ""
+}
+
+// ingressTLSSecretName returns the Secret holding the certificate of the site
+func ingressTLSSecretName(cr *v1.Wordpress) string {
+	if tls := cr.Spec.Ingress.TLS; tls != nil && tls.SecretName != "" {
+		return tls.SecretName
+	}
+	return cr.Name + "-tls"
+}
+
+// Creates the Ingress routing the hosts of the site to the WordPress Service.
+// cert-manager picks up the issuer annotation and writes the certificate to
+// the TLS Secret.
+func (r *WordpressReconciler) ingressForWordpress(cr *v1.Wordpress) *networkingv1.Ingress {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+	spec := cr.Spec.Ingress
+
+	annotations := map[string]string{}
+	for k, v := range spec.Annotations {
+		annotations[k] = v
+	}
+	if spec.TLS != nil && spec.TLS.Issuer != nil {
+		if spec.TLS.Issuer.Kind == "ClusterIssuer" {
+			annotations["cert-manager.io/cluster-issuer"] = spec.TLS.Issuer.Name
+		} else {
+			annotations["cert-manager.io/issuer"] = spec.TLS.Issuer.Name
+		}
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	var rules []networkingv1.IngressRule
+	for _, host := range spec.Hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: wordpressName(cr),
+								Port: networkingv1.ServiceBackendPort{Number: 80},
+							},
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        wordpressName(cr),
+			Namespace:   cr.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: spec.IngressClassName,
+			Rules:            rules,
+		},
+	}
+	if spec.TLS != nil {
+		ing.Spec.TLS = []networkingv1.IngressTLS{{
+			Hosts:      spec.Hosts,
+			SecretName: ingressTLSSecretName(cr),
+		}}
+	}
+
+	controllerutil.SetControllerReference(cr, ing, r.Scheme)
+	return ing
+}
+
+// Creates the HTTPRoute attaching the site to the Gateways of the spec
+func (r *WordpressReconciler) httpRouteForWordpress(cr *v1.Wordpress) (*unstructured.Unstructured, error) {
+	var parentRefs []interface{}
+	for _, ref := range cr.Spec.Gateway.ParentRefs {
+		parentRef := map[string]interface{}{
+			"name": ref.Name,
+		}
+		if ref.Namespace != "" {
+			parentRef["namespace"] = ref.Namespace
+		}
+		if ref.SectionName != "" {
+			parentRef["sectionName"] = ref.SectionName
+		}
+		parentRefs = append(parentRefs, parentRef)
+	}
+
+	spec := map[string]interface{}{
+		"parentRefs": parentRefs,
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name": wordpressName(cr),
+						"port": int64(80),
+					},
+				},
+			},
+		},
+	}
+	if len(cr.Spec.Gateway.Hostnames) > 0 {
+		var hostnames []interface{}
+		for _, hostname := range cr.Spec.Gateway.Hostnames {
+			hostnames = append(hostnames, hostname)
+		}
+		spec["hostnames"] = hostnames
+	}
+
+	route := &unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(wordpressName(cr))
+	route.SetNamespace(cr.Namespace)
+	route.SetLabels(map[string]string{"app": cr.Name})
+	route.Object["spec"] = spec
+	if err := controllerutil.SetControllerReference(cr, route, r.Scheme); err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+// httpRoutesAvailable reports whether the Gateway API CRDs are installed
+func httpRoutesAvailable(c client.Client) (bool, error) {
+	_, err := c.RESTMapper().RESTMapping(httpRouteGVK.GroupKind(), httpRouteGVK.Version)
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ensureHTTPRoute creates the HTTPRoute of the site or brings it back in line
+// with the spec. HTTPRoutes are not watched, drift is corrected on the next
+// reconcile.
+func (r *WordpressReconciler) ensureHTTPRoute(ctx context.Context, cr *v1.Wordpress) error {
+	available, err := httpRoutesAvailable(r.Client)
+	if err != nil {
+		return err
+	}
+	if !available {
+		return fmt.Errorf("cannot expose %s through a Gateway, %s", cr.Name, gatewayAPIUnavailable)
+	}
+
+	route, err := r.httpRouteForWordpress(cr)
+	if err != nil {
+		return err
+	}
+	found := &unstructured.Unstructured{}
+	found.SetGroupVersionKind(httpRouteGVK)
+	err = r.Client.Get(ctx, types.NamespacedName{Name: route.GetName(), Namespace: route.GetNamespace()}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new HTTPRoute", "HTTPRoute.Namespace", route.GetNamespace(), "HTTPRoute.Name", route.GetName())
+		return r.Client.Create(ctx, route)
+	} else if err != nil {
+		return err
+	}
+
+	if err := checkOwnership(cr, found, "HTTPRoute"); err != nil {
+		r.Log.Error(err, "Refusing to adopt HTTPRoute")
+		return err
+	}
+	if equality.Semantic.DeepDerivative(route.Object["spec"], found.Object["spec"]) {
+		return nil
+	}
+	found.Object["spec"] = route.Object["spec"]
+	r.Log.Info("Updating HTTPRoute", "HTTPRoute.Namespace", found.GetNamespace(), "HTTPRoute.Name", found.GetName())
+	return r.Client.Update(ctx, found)
+}
+
+// removeExposure deletes the Ingress or HTTPRoute of the site once the spec
+// no longer asks for it
+func (r *WordpressReconciler) removeExposure(ctx context.Context, cr *v1.Wordpress) error {
+	if cr.Spec.Ingress == nil {
+		if err := r.removeOwned(ctx, cr, &networkingv1.Ingress{}, "Ingress", wordpressName(cr)); err != nil {
+			return err
+		}
+	}
+	if cr.Spec.Gateway == nil {
+		available, err := httpRoutesAvailable(r.Client)
+		if err != nil || !available {
+			return err
+		}
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		if err := r.removeOwned(ctx, cr, route, "HTTPRoute", wordpressName(cr)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeOwned deletes an object the spec no longer asks for, if the instance
+// owns it
+func (r *WordpressReconciler) removeOwned(ctx context.Context, cr *v1.Wordpress, obj client.Object, kind, name string) error {
+	err := r.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, obj)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(obj, cr) {
+		return nil
+	}
+	r.Log.Info("Deleting "+kind, kind+".Namespace", obj.GetNamespace(), kind+".Name", obj.GetName())
+	if err := r.Client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -4,18 +4,30 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"fmt"
+	"strings"
 
 	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
-	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// mysqlDataImportedAnnotation is set on the volume of a pre-StatefulSet
+// install once its data has been copied to the primary
+const mysqlDataImportedAnnotation = "wordpress.gopkg.blogpost.com/data-imported"
+
+// mysqlRoleLabel is set on every MySQL pod to primary or replica. The pods
+// share a template, so the controller labels them by ordinal.
+const mysqlRoleLabel = "wordpress.gopkg.blogpost.com/mysql-role"
+
+// mysqlReplicationPasswordKey is the key of the MySQL Secret holding the
+// password replicas use to clone from and replicate off the primary
+const mysqlReplicationPasswordKey = "replication-password"
+
 // Function to generate a random password
 func generateRandomPassword() (string, error) {
 	length := 16
@@ -29,7 +41,7 @@ func generateRandomPassword() (string, error) {
 
 // Function to create a Kubernetes Secret with the MySQL root password
 func (r *WordpressReconciler) createMysqlPasswordSecret(cr *v1.Wordpress) (*corev1.Secret, error) {
-	secretName := "mysql-root-password-secret"
+	secretName := mysqlSecretName(cr)
 	namespace := cr.Namespace
 
 	// Check if the Secret already exists
@@ -39,12 +51,24 @@ func (r *WordpressReconciler) createMysqlPasswordSecret(cr *v1.Wordpress) (*core
 		Namespace: namespace,
 	}, secret)
 	if err == nil {
-		// Secret already exists, return it
+		// Secret already exists. Secrets created before replication was
+		// introduced lack the replication password, add one so that
+		// ensureMysqlSecret can restore it.
+		if _, ok := secret.Data[mysqlReplicationPasswordKey]; !ok {
+			replicationPassword, err := generateRandomPassword()
+			if err != nil {
+				return nil, err
+			}
+			if secret.Data == nil {
+				secret.Data = map[string][]byte{}
+			}
+			secret.Data[mysqlReplicationPasswordKey] = []byte(replicationPassword)
+		}
 		return secret, nil
 	}
 
-	// Generate a new random password if the Secret doesn't exist
-	password, err := generateRandomPassword()
+	// Generate new random passwords if the Secret doesn't exist
+	replicationPassword, err := generateRandomPassword()
 	if err != nil {
 		return nil, err
 	}
@@ -56,10 +80,30 @@ func (r *WordpressReconciler) createMysqlPasswordSecret(cr *v1.Wordpress) (*core
 			Namespace: namespace,
 		},
 		Data: map[string][]byte{
-			"password": []byte(password),
+			mysqlReplicationPasswordKey: []byte(replicationPassword),
 		},
 	}
 
+	// The root password lives in the user's Secret when one is referenced,
+	// otherwise the deprecated inline password wins over a generated one
+	if cr.Spec.CredentialsSecretRef == nil {
+		password := cr.Spec.SqlRootPassword
+		// A cloned database only knows the root password of its snapshot
+		if cr.Spec.DataSource != nil {
+			password, err = r.clonedRootPassword(cr)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if password == "" {
+			password, err = generateRandomPassword()
+			if err != nil {
+				return nil, err
+			}
+		}
+		secret.Data[mysqlRootPasswordKey] = []byte(password)
+	}
+
 	// Set the owner reference so that the Secret is cleaned up when the CR is deleted
 	if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
 		return nil, err
@@ -74,83 +118,6 @@ func (r *WordpressReconciler) createMysqlPasswordSecret(cr *v1.Wordpress) (*core
 	return secret, nil
 }
 
-// Creates a CronJob for MySQL backups
-
-func (r *WordpressReconciler) cronJobForMysqlBackup(cr *v1.Wordpress) (*batchv1.CronJob, error) {
-	labels := map[string]string{
-		"app": cr.Name,
-	}
-
-	// Ensure the MySQL Secret exists
-	secret, err := r.createMysqlPasswordSecret(cr)
-	if err != nil {
-		return nil, err
-	}
-
-	cronJob := &batchv1.CronJob{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "mysql-backup-cronjob",
-			Namespace: cr.Namespace,
-			Labels:    labels,
-		},
-		Spec: batchv1.CronJobSpec{
-			Schedule: "*/5 * * * *", // Every 5 minutes
-			JobTemplate: batchv1.JobTemplateSpec{
-				Spec: batchv1.JobSpec{
-					Template: corev1.PodTemplateSpec{
-						Spec: corev1.PodSpec{
-							Containers: []corev1.Container{
-								{
-									Name:  "mysql-backup",
-									Image: "mysql:5.6",
-									Command: []string{
-										"sh",
-										"-c",
-										"mysqldump -u root -p$MYSQL_ROOT_PASSWORD wordpress > /backup/wordpress_backup.sql",
-									},
-									Env: []corev1.EnvVar{
-										{
-											Name: "MYSQL_ROOT_PASSWORD",
-											ValueFrom: &corev1.EnvVarSource{
-												SecretKeyRef: &corev1.SecretKeySelector{
-													LocalObjectReference: corev1.LocalObjectReference{
-														Name: secret.Name,
-													},
-													Key: "password",
-												},
-											},
-										},
-									},
-									VolumeMounts: []corev1.VolumeMount{
-										{
-											Name:      "backup-storage",
-											MountPath: "/backup",
-										},
-									},
-								},
-							},
-							RestartPolicy: corev1.RestartPolicyOnFailure,
-							Volumes: []corev1.Volume{
-								{
-									Name: "backup-storage",
-									VolumeSource: corev1.VolumeSource{
-										PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-											ClaimName: "backup-pv-claim",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-	}
-
-	controllerutil.SetControllerReference(cr, cronJob, r.Scheme)
-	return cronJob, nil
-}
-
 // func (r *WordpressReconciler) cronJobForMysqlBackup(cr *v1.Wordpress) *batchv1.CronJob {
 // 	labels := map[string]string{
 // 		"app": cr.Name,
@@ -221,20 +188,16 @@ func (r *WordpressReconciler) pvcForBackup(cr *v1.Wordpress) *corev1.PersistentV
 
 	pvc := &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "backup-pv-claim",
+			Name:      backupPVCName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
 			AccessModes: []corev1.PersistentVolumeAccessMode{"ReadWriteOnce"},
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: resource.MustParse("10Gi"),
-				},
-			},
 		},
 	}
 
+	applyVolumeSpec(pvc, backupVolume(cr))
 	controllerutil.SetControllerReference(cr, pvc, r.Scheme)
 	return pvc
 }
@@ -308,7 +271,98 @@ func (r *WordpressReconciler) pvcForBackup(cr *v1.Wordpress) *corev1.PersistentV
 // 	return dep
 // }
 
-func (r *WordpressReconciler) deploymentForMysql(cr *v1.Wordpress) (*appsv1.Deployment, error) {
+// The MySQL tier runs as a StatefulSet. Pod 0 is the primary, every other pod
+// is an asynchronous replica that clones the primary with the CLONE plugin and
+// then follows it with GTID auto-positioning. There is no automatic failover.
+// Both rely on MySQL 8.0.22 or later.
+
+// mysqlInitScript writes the per-pod server configuration
+const mysqlInitScript = `set -ex
+[[ $HOSTNAME =~ -([0-9]+)$ ]] || exit 1
+ordinal=${BASH_REMATCH[1]}
+cat > /mnt/conf.d/replication.cnf <<EOF
+[mysqld]
+server-id=$((100 + ordinal))
+gtid_mode=ON
+enforce_gtid_consistency=ON
+log_bin=mysql-bin
+binlog_format=ROW
+plugin-load-add=mysql_clone.so
+EOF
+if [ "$ordinal" -gt 0 ]; then
+  echo "read_only=ON" >> /mnt/conf.d/replication.cnf
+fi
+`
+
+// mysqlImportLegacyScript copies the data of a pre-StatefulSet install into
+// the primary's volume, once, while the data directory is still empty
+const mysqlImportLegacyScript = `set -ex
+[[ $HOSTNAME =~ -0$ ]] || exit 0
+if [ -d /var/lib/mysql/mysql ]; then
+  exit 0
+fi
+cp -a /legacy-data/. /var/lib/mysql/
+`
+
+// mysqlReplicationScript runs next to mysqld. On the primary it maintains the
+// replication user, on replicas it clones the primary and starts replication.
+const mysqlReplicationScript = `set -e
+[[ $HOSTNAME =~ -([0-9]+)$ ]] || exit 1
+ordinal=${BASH_REMATCH[1]}
+local_mysql() { mysql -h 127.0.0.1 -uroot -p"$MYSQL_ROOT_PASSWORD" "$@"; }
+wait_for_mysql() {
+  until local_mysql -e "SELECT 1" >/dev/null 2>&1; do sleep 2; done
+}
+
+wait_for_mysql
+if [ "$ordinal" -eq 0 ]; then
+  local_mysql -e "CREATE USER IF NOT EXISTS 'replication'@'%' IDENTIFIED BY '$MYSQL_REPLICATION_PASSWORD';
+    ALTER USER 'replication'@'%' IDENTIFIED BY '$MYSQL_REPLICATION_PASSWORD';
+    GRANT REPLICATION SLAVE, BACKUP_ADMIN ON *.* TO 'replication'@'%';"
+else
+  cloned() {
+    [ -n "$(local_mysql -N -e "SELECT STATE FROM performance_schema.clone_status WHERE STATE='Completed'")" ]
+  }
+  if ! cloned; then
+    # mysqld shuts down once the clone is done, which drops the connection,
+    # the kubelet restarts the container and we wait for it to come back
+    local_mysql -e "SET GLOBAL clone_valid_donor_list='$MYSQL_PRIMARY_HOST:3306';
+      CLONE INSTANCE FROM 'replication'@'$MYSQL_PRIMARY_HOST':3306 IDENTIFIED BY '$MYSQL_REPLICATION_PASSWORD';" || true
+    sleep 5
+    wait_for_mysql
+    # The dropped connection hides whether the clone failed, exit to have
+    # the kubelet restart this container and clone again
+    if ! cloned; then
+      echo "Cloning $MYSQL_PRIMARY_HOST failed:" >&2
+      local_mysql -N -e "SELECT STATE, ERROR_NO, ERROR_MESSAGE FROM performance_schema.clone_status" >&2 || true
+      exit 1
+    fi
+  fi
+  if [ -z "$(local_mysql -N -e "SHOW REPLICA STATUS")" ]; then
+    local_mysql -e "CHANGE REPLICATION SOURCE TO SOURCE_HOST='$MYSQL_PRIMARY_HOST',
+      SOURCE_USER='replication', SOURCE_PASSWORD='$MYSQL_REPLICATION_PASSWORD',
+      SOURCE_AUTO_POSITION=1, GET_SOURCE_PUBLIC_KEY=1;
+      START REPLICA;"
+  fi
+fi
+while true; do sleep 3600; done
+`
+
+// mysqlPrimaryHost is the stable DNS name of the primary, pod 0 of the
+// StatefulSet behind the headless Service
+func mysqlPrimaryHost(cr *v1.Wordpress) string {
+	return mysqlName(cr) + "-0." + mysqlName(cr)
+}
+
+// mysqlReadName is the name of the Service spreading reads over the MySQL
+// replicas
+func mysqlReadName(cr *v1.Wordpress) string {
+	return mysqlName(cr) + "-read"
+}
+
+// Creates a StatefulSet for MySQL. legacyPVC is the name of the volume of a
+// pre-StatefulSet install whose data still has to be imported, or empty.
+func (r *WordpressReconciler) statefulSetForMysql(cr *v1.Wordpress, legacyPVC string) (*appsv1.StatefulSet, error) {
 	labels := map[string]string{
 		"app": cr.Name,
 	}
@@ -317,80 +371,150 @@ func (r *WordpressReconciler) deploymentForMysql(cr *v1.Wordpress) (*appsv1.Depl
 		"tier": "mysql",
 	}
 
-	// Setting the replicas for the MySQL deployment
-	replicas := int32(*cr.Spec.MysqlReplicas)
+	// Setting the replicas for the MySQL StatefulSet, the primary is always there
+	replicas := int32(1)
+	if cr.Spec.MysqlReplicas != nil && *cr.Spec.MysqlReplicas > 0 {
+		replicas = *cr.Spec.MysqlReplicas
+	}
+	// Replicas clone the primary, so wait until it holds the imported data
+	if legacyPVC != "" {
+		replicas = 1
+	}
 
-	// Get or create the MySQL root password Secret
+	// Get or create the MySQL Secret holding the replication password
 	secret, err := r.createMysqlPasswordSecret(cr)
 	if err != nil {
 		return nil, err
 	}
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
 
-	dep := &appsv1.Deployment{
+	env := []corev1.EnvVar{
+		secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+	}
+	replicationEnv := append([]corev1.EnvVar{
+		secretEnv("MYSQL_REPLICATION_PASSWORD", secretKey(secret.Name, mysqlReplicationPasswordKey)),
+		{
+			Name:  "MYSQL_PRIMARY_HOST",
+			Value: mysqlPrimaryHost(cr),
+		},
+	}, env...)
+
+	initContainers := []corev1.Container{{
+		Name:    "init-mysql",
+		Image:   mysqlImage(cr),
+		Command: []string{"bash", "-c", mysqlInitScript},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "mysql-conf",
+				MountPath: "/mnt/conf.d",
+			},
+		},
+	}}
+	volumes := []corev1.Volume{
+		{
+			Name: "mysql-conf",
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	if legacyPVC != "" {
+		initContainers = append(initContainers, corev1.Container{
+			Name:    "import-legacy-data",
+			Image:   mysqlImage(cr),
+			Command: []string{"bash", "-c", mysqlImportLegacyScript},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "mysql-persistent-storage",
+					MountPath: "/var/lib/mysql",
+				},
+				{
+					Name:      "legacy-data",
+					MountPath: "/legacy-data",
+					ReadOnly:  true,
+				},
+			},
+		})
+		volumes = append(volumes, corev1.Volume{
+			Name: "legacy-data",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: legacyPVC,
+					ReadOnly:  true,
+				},
+			},
+		})
+	}
+
+	sts := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "wordpress-mysql",
+			Name:      mysqlName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
 
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas, // Set the replicas here
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: mysqlName(cr),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: matchlabels,
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: matchlabels,
+					Labels:      matchlabels,
+					Annotations: credentialsRotatedAt(cr),
 				},
 				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{{
-						Image: "mysql",
-						Name:  "mysql",
-						Env: []corev1.EnvVar{
-							{
-								Name: "MYSQL_ROOT_PASSWORD",
-								ValueFrom: &corev1.EnvVarSource{
-									SecretKeyRef: &corev1.SecretKeySelector{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: secret.Name,
-										},
-										Key: "password",
-									},
+					InitContainers: initContainers,
+					Containers: []corev1.Container{
+						{
+							Image: mysqlImage(cr),
+							Name:  "mysql",
+							Env:   env,
+							Ports: []corev1.ContainerPort{{
+								ContainerPort: 3306,
+								Name:          "mysql",
+							}},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "mysql-persistent-storage",
+									MountPath: "/var/lib/mysql",
+								},
+								{
+									Name:      "mysql-conf",
+									MountPath: "/etc/mysql/conf.d",
 								},
 							},
 						},
-						Ports: []corev1.ContainerPort{{
-							ContainerPort: 3306,
-							Name:          "mysql",
-						}},
-						VolumeMounts: []corev1.VolumeMount{
-							{
-								Name:      "mysql-persistent-storage",
-								MountPath: "/var/lib/mysql",
-							},
-						},
-					}},
-					Volumes: []corev1.Volume{
 						{
-							Name: "mysql-persistent-storage",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "mysql-pv-claim",
-								},
-							},
+							Image:   mysqlImage(cr),
+							Name:    "replication",
+							Command: []string{"bash", "-c", mysqlReplicationScript},
+							Env:     replicationEnv,
 						},
 					},
+					Volumes: volumes,
 				},
 			},
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{*r.pvcForMysql(cr)},
 		},
 	}
 
-	// Set owner reference so that deployment is cleaned up when the CR is deleted
-	controllerutil.SetControllerReference(cr, dep, r.Scheme)
-	return dep, nil
+	applyProbes(&sts.Spec.Template.Spec.Containers[0], mysqlProbes(), mysqlProbeOverrides(cr))
+	applyPodOverrides(&sts.Spec.Template.Spec, podOverrides(cr, "mysql"), &mysqlResources, spreadAcrossNodes(matchlabels))
+	applyMysqlSecurityContext(cr, &sts.Spec.Template.Spec)
+	applyServiceAccount(cr, "mysql", &sts.Spec.Template.Spec)
+	applyImagePolicy(cr, &sts.Spec.Template.Spec)
+
+	// Set owner reference so that the StatefulSet is cleaned up when the CR is deleted
+	controllerutil.SetControllerReference(cr, sts, r.Scheme)
+	return sts, nil
 }
 
-// Creates a PersistentVolumeClaim for MySQL
+// Creates the PersistentVolumeClaim template each MySQL pod gets its volume from
 func (r *WordpressReconciler) pvcForMysql(cr *v1.Wordpress) *corev1.PersistentVolumeClaim {
 	labels := map[string]string{
 		"app": cr.Name,
@@ -399,9 +523,8 @@ func (r *WordpressReconciler) pvcForMysql(cr *v1.Wordpress) *corev1.PersistentVo
 	pvc := &corev1.PersistentVolumeClaim{
 
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "mysql-pv-claim",
-			Namespace: cr.Namespace,
-			Labels:    labels,
+			Name:   "mysql-persistent-storage",
+			Labels: labels,
 		},
 
 		Spec: corev1.PersistentVolumeClaimSpec{
@@ -409,21 +532,26 @@ func (r *WordpressReconciler) pvcForMysql(cr *v1.Wordpress) *corev1.PersistentVo
 			AccessModes: []corev1.PersistentVolumeAccessMode{
 				"ReadWriteOnce",
 			},
-
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: resource.MustParse("10Gi"),
-				},
-			},
 		},
 	}
+	applyVolumeSpec(pvc, mysqlVolume(cr))
 
-	controllerutil.SetControllerReference(cr, pvc, r.Scheme)
 	return pvc
 
 }
 
-// Creates a Service for MySQL
+// Creates the PersistentVolumeClaim of the primary the way the StatefulSet
+// would, for volumes that are created ahead of it. Like the volumes the
+// StatefulSet creates it is not owned by the CR.
+func (r *WordpressReconciler) pvcForMysqlPrimary(cr *v1.Wordpress) *corev1.PersistentVolumeClaim {
+	pvc := r.pvcForMysql(cr)
+	pvc.Name = mysqlPrimaryPVCName(cr)
+	pvc.Namespace = cr.Namespace
+	return pvc
+}
+
+// Creates a Service for MySQL. It is headless and gives every pod, the
+// primary in particular, a stable DNS name.
 func (r *WordpressReconciler) serviceForMysql(cr *v1.Wordpress) *corev1.Service {
 	labels := map[string]string{
 		"app": cr.Name,
@@ -436,7 +564,7 @@ func (r *WordpressReconciler) serviceForMysql(cr *v1.Wordpress) *corev1.Service
 	ser := &corev1.Service{
 
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "wordpress-mysql",
+			Name:      mysqlName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
@@ -459,23 +587,158 @@ func (r *WordpressReconciler) serviceForMysql(cr *v1.Wordpress) *corev1.Service
 
 }
 
-// Checks if the MySQL deployment is up
-func (r *WordpressReconciler) isMysqlUp(v *v1.Wordpress) bool {
-	deployment := &appsv1.Deployment{}
+// Creates a read-only Service that balances over the MySQL replicas. The
+// primary is left out, it takes the writes.
+func (r *WordpressReconciler) serviceForMysqlRead(cr *v1.Wordpress) *corev1.Service {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+	matchlabels := map[string]string{
+		"app":          cr.Name,
+		"tier":         "mysql",
+		mysqlRoleLabel: "replica",
+	}
+
+	ser := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlReadName(cr),
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: matchlabels,
+			Ports: []corev1.ServicePort{
+				{
+					Port: 3306,
+					Name: "mysql",
+				},
+			},
+		},
+	}
 
+	controllerutil.SetControllerReference(cr, ser, r.Scheme)
+	return ser
+}
+
+// legacyMysqlVolume returns the name of the single MySQL volume used before
+// the StatefulSet, if it exists and its data was not imported yet
+func (r *WordpressReconciler) legacyMysqlVolume(cr *v1.Wordpress) (string, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
 	err := r.Client.Get(context.TODO(), types.NamespacedName{
-		Name:      "wordpress-mysql",
-		Namespace: v.Namespace,
-	}, deployment)
+		Name:      mysqlPVCName(cr),
+		Namespace: cr.Namespace,
+	}, pvc)
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if !metav1.IsControlledBy(pvc, cr) || pvc.Annotations[mysqlDataImportedAnnotation] == "true" {
+		return "", nil
+	}
+	return pvc.Name, nil
+}
 
+// markLegacyMysqlVolumeImported records on the old volume that its data now
+// lives in the StatefulSet. The volume itself is kept for the user to remove.
+func (r *WordpressReconciler) markLegacyMysqlVolumeImported(cr *v1.Wordpress, name string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: cr.Namespace,
+	}, pvc)
 	if err != nil {
-		r.Log.Error(err, "Deployment mysql not found")
-		fmt.Println(err, "Deployment mysql not found")
+		return err
+	}
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	pvc.Annotations[mysqlDataImportedAnnotation] = "true"
+	r.Log.Info("Legacy MySQL data imported", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name)
+	return r.Client.Update(context.TODO(), pvc)
+}
 
+// removeLegacyMysqlDeployment deletes the MySQL Deployment of a
+// pre-StatefulSet install so that it releases its volume
+func (r *WordpressReconciler) removeLegacyMysqlDeployment(cr *v1.Wordpress) error {
+	dep := &appsv1.Deployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      mysqlName(cr),
+		Namespace: cr.Namespace,
+	}, dep)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(dep, cr) {
+		return nil
+	}
+	r.Log.Info("Removing legacy MySQL Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+	return client.IgnoreNotFound(r.Client.Delete(context.TODO(), dep))
+}
+
+// ensureMysqlRoles labels each MySQL pod with its role, pod 0 is the primary
+// and the others are replicas
+func (r *WordpressReconciler) ensureMysqlRoles(ctx context.Context, cr *v1.Wordpress) error {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(cr.Namespace), client.MatchingLabels{"app": cr.Name, "tier": "mysql"}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		ordinal, ok := strings.CutPrefix(pod.Name, mysqlName(cr)+"-")
+		if !ok || !isOrdinal(ordinal) {
+			continue
+		}
+		role := "replica"
+		if ordinal == "0" {
+			role = "primary"
+		}
+		if pod.Labels[mysqlRoleLabel] == role {
+			continue
+		}
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[mysqlRoleLabel] = role
+		r.Log.Info("Labelling MySQL pod", "Pod.Namespace", pod.Namespace, "Pod.Name", pod.Name, "Role", role)
+		if err := r.Client.Patch(ctx, pod, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks if the MySQL primary is up and records the number of ready MySQL
+// pods in the status. Replicas may still be cloning, WordPress only needs
+// the primary. A pod is only ready once its readiness probe got an answer
+// to a query, a running process is not enough.
+func (r *WordpressReconciler) isMysqlUp(v *v1.Wordpress) bool {
+	sts := &appsv1.StatefulSet{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      mysqlName(v),
+		Namespace: v.Namespace,
+	}, sts)
+	if err != nil {
+		r.Log.Error(err, "StatefulSet mysql not found")
+		return false
+	}
+	v.Status.MysqlReadyReplicas = sts.Status.ReadyReplicas
+
+	primary := &corev1.Pod{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      mysqlName(v) + "-0",
+		Namespace: v.Namespace,
+	}, primary)
+	if err != nil {
 		return false
 	}
-	if deployment.Status.ReadyReplicas == 1 {
-		return true
+	for _, cond := range primary.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
 	}
 
 	return false
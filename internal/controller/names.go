@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Object names used before they were derived from the CR name. Instances
+// created back then keep using them, see usesLegacyNames.
+const (
+	legacyWordpressName     = "wordpress"
+	legacyMysqlName         = "wordpress-mysql"
+	legacyWordpressPVCName  = "wp-pv-claim"
+	legacyMysqlPVCName      = "mysql-pv-claim"
+	legacyBackupPVCName     = "backup-pv-claim"
+	legacyMysqlSecretName   = "mysql-root-password-secret"
+	legacyBackupCronJobName = "mysql-backup-cronjob"
+)
+
+// usesLegacyNames reports whether the instance keeps the fixed object names
+func usesLegacyNames(cr *v1.Wordpress) bool {
+	return cr.Annotations[v1.LegacyNamingAnnotation] == "true"
+}
+
+// instanceName prefixes a legacy object name with the CR name so that
+// several instances can live in one namespace
+func instanceName(cr *v1.Wordpress, legacyName string) string {
+	if usesLegacyNames(cr) {
+		return legacyName
+	}
+	return cr.Name + "-" + legacyName
+}
+
+// wordpressName is the name of the WordPress Deployment and Service
+func wordpressName(cr *v1.Wordpress) string {
+	if usesLegacyNames(cr) {
+		return legacyWordpressName
+	}
+	return cr.Name + "-wordpress"
+}
+
+// mysqlName is the name of the MySQL workload and Service, and therefore
+// also the database host WordPress connects to
+func mysqlName(cr *v1.Wordpress) string {
+	if usesLegacyNames(cr) {
+		return legacyMysqlName
+	}
+	return cr.Name + "-mysql"
+}
+
+func wordpressPVCName(cr *v1.Wordpress) string {
+	return instanceName(cr, legacyWordpressPVCName)
+}
+
+func mysqlPVCName(cr *v1.Wordpress) string {
+	return instanceName(cr, legacyMysqlPVCName)
+}
+
+func backupPVCName(cr *v1.Wordpress) string {
+	return instanceName(cr, legacyBackupPVCName)
+}
+
+func mysqlSecretName(cr *v1.Wordpress) string {
+	return instanceName(cr, legacyMysqlSecretName)
+}
+
+func backupCronJobName(cr *v1.Wordpress) string {
+	return instanceName(cr, legacyBackupCronJobName)
+}
+
+// wordpressDBSecretName is the name of the Secret holding the generated
+// WordPress database user
+func wordpressDBSecretName(cr *v1.Wordpress) string {
+	return wordpressName(cr) + "-db-secret"
+}
+
+// mysqlUserBootstrapJobName is the name of the Job creating the WordPress
+// database user
+func mysqlUserBootstrapJobName(cr *v1.Wordpress) string {
+	return mysqlName(cr) + "-user-bootstrap"
+}
+
+// detectLegacyNames marks instances that were installed with the fixed
+// object names. If the legacy MySQL volume exists and belongs to this CR the
+// annotation is persisted, so the decision survives the volume being renamed
+// or removed later on.
+func (r *WordpressReconciler) detectLegacyNames(ctx context.Context, cr *v1.Wordpress) error {
+	if _, ok := cr.Annotations[v1.LegacyNamingAnnotation]; ok {
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Client.Get(ctx, types.NamespacedName{
+		Name:      legacyMysqlPVCName,
+		Namespace: cr.Namespace,
+	}, pvc)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(pvc, cr) {
+		// A legacy instance deleted under the Retain policy left the
+		// volume behind for whoever takes its name
+		adopted, err := r.adoptRetained(cr, pvc)
+		if err != nil || !adopted {
+			return err
+		}
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			return err
+		}
+	}
+
+	r.Log.Info("Keeping legacy object names", "Wordpress.Namespace", cr.Namespace, "Wordpress.Name", cr.Name)
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	cr.Annotations[v1.LegacyNamingAnnotation] = "true"
+	// Pin the image the instance was installed with
+	if cr.Spec.DatabaseImage == "" {
+		cr.Spec.DatabaseImage = v1.LegacyDatabaseImage
+	}
+	return r.Client.Update(ctx, cr)
+}
+
+// backupJobName is the name of the Job taking a backup
+func backupJobName(backup *v1.WordpressBackup) string {
+	return backup.Name + "-backup"
+}
+
+// backupCleanupJobName is the name of the Job removing the backup set of a
+// deleted backup
+func backupCleanupJobName(backup *v1.WordpressBackup) string {
+	return backup.Name + "-cleanup"
+}
+
+// restoreJobName is the name of the Job running a stage of a restore
+func restoreJobName(restore *v1.WordpressRestore, stage string) string {
+	return restore.Name + "-" + stage
+}
+
+// finalBackupName is the name of the backup the Snapshot deletion policy
+// takes. It is named after the deletion time, like scheduled backups are
+// after theirs, so that it does not clash with one a previous instance of
+// the same name left behind.
+func finalBackupName(cr *v1.Wordpress) string {
+	return fmt.Sprintf("%s-final-%s", cr.Name, cr.DeletionTimestamp.UTC().Format("20060102150405"))
+}
+
+// serviceAccountName is the name of the ServiceAccount of a tier, wordpress,
+// mysql or jobs. ServiceAccounts came after the legacy names, they always
+// carry the name of the instance.
+func serviceAccountName(cr *v1.Wordpress, tier string) string {
+	return cr.Name + "-" + tier
+}
+
+// mysqlPrimaryPVCName is the name of the volume the StatefulSet creates for
+// the MySQL primary from its volume claim template
+func mysqlPrimaryPVCName(cr *v1.Wordpress) string {
+	return "mysql-persistent-storage-" + mysqlName(cr) + "-0"
+}
+
+// tableLockJobName is the name of the Job holding the tables locked while a
+// VolumeSnapshot backup is taken
+func tableLockJobName(backup *v1.WordpressBackup) string {
+	return backup.Name + "-lock"
+}
+
+// volumeSnapshotName is the name of the VolumeSnapshot a backup takes of a volume
+func volumeSnapshotName(backup *v1.WordpressBackup, volume string) string {
+	return backup.Name + "-" + volume
+}
+
+// snapshotCredentialsSecretName is the name of the Secret keeping the root
+// password a VolumeSnapshot backup was taken with
+func snapshotCredentialsSecretName(backup *v1.WordpressBackup) string {
+	return backup.Name + "-credentials"
+}
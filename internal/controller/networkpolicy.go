@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"context"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// databaseIsolated reports whether the database NetworkPolicy is wanted
+func databaseIsolated(cr *v1.Wordpress) bool {
+	if cr.Spec.NetworkPolicies == nil || cr.Spec.NetworkPolicies.Database == nil {
+		return true
+	}
+	return *cr.Spec.NetworkPolicies.Database
+}
+
+// wordpressEgress returns the egress policy of WordPress, if any
+func wordpressEgress(cr *v1.Wordpress) *v1.EgressPolicy {
+	if cr.Spec.NetworkPolicies == nil {
+		return nil
+	}
+	return cr.Spec.NetworkPolicies.WordpressEgress
+}
+
+// egressPolicyName is the name of the egress NetworkPolicy of WordPress
+func egressPolicyName(cr *v1.Wordpress) string {
+	return wordpressName(cr) + "-egress"
+}
+
+func tcpPort(port int) networkingv1.NetworkPolicyPort {
+	protocol := corev1.ProtocolTCP
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p}
+}
+
+// Creates the NetworkPolicy letting only WordPress, the Jobs of the
+// instance and the other MySQL pods connect to MySQL. Replicas clone and
+// follow the primary over the MySQL port too.
+func (r *WordpressReconciler) networkPolicyForMysql(cr *v1.Wordpress) *networkingv1.NetworkPolicy {
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      mysqlName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":  cr.Name,
+					"tier": "mysql",
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: []networkingv1.NetworkPolicyPort{tcpPort(3306)},
+				From: []networkingv1.NetworkPolicyPeer{
+					{
+						PodSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								databaseClientLabel: cr.Name,
+							},
+						},
+					},
+					{
+						PodSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"app":  cr.Name,
+								"tier": "mysql",
+							},
+						},
+					},
+				},
+			}},
+		},
+	}
+
+	controllerutil.SetControllerReference(cr, np, r.Scheme)
+	return np
+}
+
+// Creates the NetworkPolicy only letting WordPress reach MySQL, DNS and
+// the destinations of the egress policy of the spec
+func (r *WordpressReconciler) egressPolicyForWordpress(cr *v1.Wordpress) *networkingv1.NetworkPolicy {
+	udp := corev1.ProtocolUDP
+	dns := intstr.FromInt(53)
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{tcpPort(3306)},
+			To: []networkingv1.NetworkPolicyPeer{{
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{
+						"app":  cr.Name,
+						"tier": "mysql",
+					},
+				},
+			}},
+		},
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dns},
+				tcpPort(53),
+			},
+			To: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{},
+			}},
+		},
+	}
+	for i := range wordpressEgress(cr).Rules {
+		egress = append(egress, *wordpressEgress(cr).Rules[i].DeepCopy())
+	}
+
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      egressPolicyName(cr),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app":  cr.Name,
+					"tier": "frontend",
+				},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      egress,
+		},
+	}
+
+	controllerutil.SetControllerReference(cr, np, r.Scheme)
+	return np
+}
+
+// ensureNetworkPolicies creates the NetworkPolicies the spec asks for and
+// deletes the ones it no longer does
+func (r *WordpressReconciler) ensureNetworkPolicies(request reconcile.Request, instance *v1.Wordpress) (*reconcile.Result, error) {
+	if databaseIsolated(instance) {
+		if result, err := r.ensureNetworkPolicy(request, instance, r.networkPolicyForMysql(instance)); result != nil || err != nil {
+			return result, err
+		}
+	} else if err := r.removeOwned(context.TODO(), instance, &networkingv1.NetworkPolicy{}, "NetworkPolicy", mysqlName(instance)); err != nil {
+		return nil, err
+	}
+
+	if wordpressEgress(instance) != nil {
+		return r.ensureNetworkPolicy(request, instance, r.egressPolicyForWordpress(instance))
+	}
+	return nil, r.removeOwned(context.TODO(), instance, &networkingv1.NetworkPolicy{}, "NetworkPolicy", egressPolicyName(instance))
+}
+
+func (r *WordpressReconciler) ensureNetworkPolicy(_ reconcile.Request,
+	instance *v1.Wordpress,
+	np *networkingv1.NetworkPolicy,
+) (*reconcile.Result, error) {
+
+	found := &networkingv1.NetworkPolicy{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      np.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new NetworkPolicy", "NetworkPolicy.Namespace", np.Namespace, "NetworkPolicy.Name", np.Name)
+		if err := r.Client.Create(context.TODO(), np); err != nil {
+			r.Log.Error(err, "Failed to create new NetworkPolicy", "NetworkPolicy.Namespace", np.Namespace, "NetworkPolicy.Name", np.Name)
+			return &reconcile.Result{}, err
+		}
+		return nil, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get NetworkPolicy")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "NetworkPolicy"); err != nil {
+		r.Log.Error(err, "Refusing to adopt NetworkPolicy")
+		return &ctrl.Result{}, err
+	}
+
+	// The API server fills in the protocol of ports, DeepDerivative does not
+	// count it as drift
+	labelsChanged := mergeLabels(&found.ObjectMeta, np.Labels)
+	if !labelsChanged &&
+		len(np.Spec.Ingress) == len(found.Spec.Ingress) &&
+		len(np.Spec.Egress) == len(found.Spec.Egress) &&
+		equality.Semantic.DeepDerivative(np.Spec, found.Spec) {
+		return nil, nil
+	}
+
+	found.Spec = np.Spec
+	r.Log.Info("Updating NetworkPolicy", "NetworkPolicy.Namespace", found.Namespace, "NetworkPolicy.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update NetworkPolicy", "NetworkPolicy.Namespace", found.Namespace, "NetworkPolicy.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+	return nil, nil
+}
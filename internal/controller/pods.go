@@ -0,0 +1,108 @@
+package controller
+
+import (
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Default requests of the containers. Pods without requests are the first to
+// be evicted when a node runs short, the database must not be. A container
+// without requests also lets the whole pod be squeezed, so sidecars get some.
+var (
+	wordpressResources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}
+	mysqlResources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("250m"),
+			corev1.ResourceMemory: resource.MustParse("512Mi"),
+		},
+	}
+	sidecarResources = corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("25m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+)
+
+// podOverrides returns the overrides of the spec for the pods of a tier
+func podOverrides(cr *v1.Wordpress, tier string) *v1.PodTemplateOverrides {
+	if cr.Spec.Pods == nil {
+		return nil
+	}
+	switch tier {
+	case "frontend":
+		return cr.Spec.Pods.Wordpress
+	case "mysql":
+		return cr.Spec.Pods.Mysql
+	default:
+		return cr.Spec.Pods.Jobs
+	}
+}
+
+// spreadAcrossNodes returns an anti-affinity preferring to run the pods
+// matching the labels on different nodes
+func spreadAcrossNodes(matchLabels map[string]string) *corev1.Affinity {
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+				Weight: 100,
+				PodAffinityTerm: corev1.PodAffinityTerm{
+					LabelSelector: &metav1.LabelSelector{
+						MatchLabels: matchLabels,
+					},
+					TopologyKey: corev1.LabelHostname,
+				},
+			}},
+		},
+	}
+}
+
+// applyPodOverrides sets the resources of the main container, the first one,
+// and of the sidecars after it, and the placement of a pod spec built by the
+// operator. defaultResources and defaultAffinity apply when the overrides do
+// not set their own, without them the affinity the builder set is kept. The
+// sidecars get sidecarResources along with defaultResources.
+func applyPodOverrides(spec *corev1.PodSpec, o *v1.PodTemplateOverrides, defaultResources *corev1.ResourceRequirements, defaultAffinity *corev1.Affinity) {
+	sidecars := spec.Containers[1:]
+	if defaultResources != nil {
+		spec.Containers[0].Resources = *defaultResources.DeepCopy()
+		for i := range sidecars {
+			sidecars[i].Resources = *sidecarResources.DeepCopy()
+		}
+	}
+	if defaultAffinity != nil {
+		spec.Affinity = defaultAffinity
+	}
+	if o == nil {
+		return
+	}
+	if o.Resources != nil {
+		spec.Containers[0].Resources = *o.Resources.DeepCopy()
+	}
+	if o.SidecarResources != nil {
+		for i := range sidecars {
+			sidecars[i].Resources = *o.SidecarResources.DeepCopy()
+		}
+	}
+	if o.Affinity != nil {
+		spec.Affinity = o.Affinity.DeepCopy()
+	}
+	spec.NodeSelector = o.NodeSelector
+	spec.Tolerations = o.Tolerations
+	spec.TopologySpreadConstraints = o.TopologySpreadConstraints
+	spec.PriorityClassName = o.PriorityClassName
+}
+
+// applyJobOverrides applies the Jobs overrides of the spec to the pod spec
+// of a Job and runs it under the ServiceAccount of the Jobs
+func applyJobOverrides(cr *v1.Wordpress, spec *corev1.PodSpec) {
+	applyPodOverrides(spec, podOverrides(cr, "jobs"), nil, nil)
+	applyServiceAccount(cr, "jobs", spec)
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// mysqlReadinessScript runs a query over TCP. The entrypoint of the image
+// initialises the data directory with networking turned off, so the pod only
+// becomes ready once the real server accepts connections.
+const mysqlReadinessScript = `MYSQL_PWD="$MYSQL_ROOT_PASSWORD" mysql -h 127.0.0.1 -uroot -e 'SELECT 1'`
+
+// mysqlPingScript only checks that the server answers. mysqladmin ping
+// succeeds even when access is denied, a rotated password cannot fail it.
+const mysqlPingScript = `mysqladmin ping -h 127.0.0.1 --silent`
+
+// mysqlProbes returns the default probes of the MySQL container. Startup
+// gives a large database ten minutes to recover before liveness takes over.
+func mysqlProbes() *v1.ContainerProbes {
+	return &v1.ContainerProbes{
+		Readiness: &corev1.Probe{
+			ProbeHandler:     execHandler(mysqlReadinessScript),
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		},
+		Liveness: &corev1.Probe{
+			ProbeHandler:     execHandler(mysqlPingScript),
+			PeriodSeconds:    20,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		},
+		Startup: &corev1.Probe{
+			ProbeHandler:     execHandler(mysqlPingScript),
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 60,
+		},
+	}
+}
+
+// wordpressProbes returns the default probes of the WordPress container.
+// install.php loads WordPress without redirecting and fails while the
+// database cannot be reached, so readiness follows the database. Liveness
+// only checks the port, a database outage must not restart WordPress. The
+// port is the one of the container, readiness goes through the web server.
+func wordpressProbes(port int32) *v1.ContainerProbes {
+	return &v1.ContainerProbes{
+		Readiness: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path:   "/wp-admin/install.php",
+					Port:   intstr.FromInt(80),
+					Scheme: corev1.URISchemeHTTP,
+				},
+			},
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		},
+		Liveness: &corev1.Probe{
+			ProbeHandler:     tcpHandler(port),
+			PeriodSeconds:    20,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 3,
+		},
+		// The entrypoint copies WordPress into an empty volume first
+		Startup: &corev1.Probe{
+			ProbeHandler:     tcpHandler(port),
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			SuccessThreshold: 1,
+			FailureThreshold: 30,
+		},
+	}
+}
+
+// applyProbes sets the probes of a container, each override of the spec
+// replacing its default
+func applyProbes(container *corev1.Container, defaults, overrides *v1.ContainerProbes) {
+	container.ReadinessProbe = defaults.Readiness
+	container.LivenessProbe = defaults.Liveness
+	container.StartupProbe = defaults.Startup
+	if overrides == nil {
+		return
+	}
+	if overrides.Readiness != nil {
+		container.ReadinessProbe = overrides.Readiness.DeepCopy()
+	}
+	if overrides.Liveness != nil {
+		container.LivenessProbe = overrides.Liveness.DeepCopy()
+	}
+	if overrides.Startup != nil {
+		container.StartupProbe = overrides.Startup.DeepCopy()
+	}
+}
+
+// mysqlProbeOverrides returns the MySQL probes of the spec, if any
+func mysqlProbeOverrides(cr *v1.Wordpress) *v1.ContainerProbes {
+	if cr.Spec.Probes == nil {
+		return nil
+	}
+	return cr.Spec.Probes.Mysql
+}
+
+// wordpressProbeOverrides returns the WordPress probes of the spec, if any
+func wordpressProbeOverrides(cr *v1.Wordpress) *v1.ContainerProbes {
+	if cr.Spec.Probes == nil {
+		return nil
+	}
+	return cr.Spec.Probes.Wordpress
+}
+
+func execHandler(script string) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"bash", "-c", script},
+		},
+	}
+}
+
+func tcpHandler(port int32) corev1.ProbeHandler {
+	return corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{
+			Port: intstr.FromInt(int(port)),
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// restoreFetchScript downloads a backup set from S3 for the restore Jobs
+const restoreFetchScript = `set -eo pipefail
+` + s3CLISetup + `
+aws "${endpoint[@]}" s3 cp --recursive "s3://$S3_BUCKET/$S3_PREFIX/$BACKUP_NAME/" "/backup/$BACKUP_NAME/"
+`
+
+// restoreDatabaseScript verifies the backup set and replaces the database
+// with its dump. The grants of the WordPress user are kept, MySQL does not
+// drop them with the database.
+const restoreDatabaseScript = `set -eo pipefail
+cd "/backup/$BACKUP_NAME"
+if [ -f SHA256SUMS ]; then
+  sha256sum -c SHA256SUMS
+fi
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+until mysqladmin ping -h "$MYSQL_HOST" -u root --silent; do sleep 2; done
+mysql -h "$MYSQL_HOST" -u root -e "DROP DATABASE IF EXISTS wordpress; CREATE DATABASE wordpress"
+gunzip -c database.sql.gz | mysql -h "$MYSQL_HOST" -u root wordpress
+echo "Restored the database from $BACKUP_NAME"
+`
+
+// restoreContentScript verifies the content archive of the backup set and
+// replaces the site content with it
+const restoreContentScript = `set -e
+cd "/backup/$BACKUP_NAME"
+if [ ! -f content.tar.gz ]; then
+  echo "$BACKUP_NAME holds no content, keeping the current files"
+  exit 0
+fi
+if [ -f SHA256SUMS ]; then
+  grep ' content.tar.gz$' SHA256SUMS | sha256sum -c -
+fi
+find /var/www/html -mindepth 1 -delete
+tar -xzf content.tar.gz -C /var/www/html
+echo "Restored the content from $BACKUP_NAME"
+`
+
+// Stages of a restore run as Jobs
+const (
+	restoreDatabaseStage = "database"
+	restoreContentStage  = "content"
+)
+
+// restoreInProgress reports whether a restore has stopped the instance
+func restoreInProgress(cr *v1.Wordpress) bool {
+	return cr.Annotations[v1.RestoreInProgressAnnotation] != ""
+}
+
+// restoreSource is the backup set a restore reads from
+type restoreSource struct {
+	name      string
+	claimName string
+	s3        *v1.S3Destination
+	// includesContent is false when the backup is known to hold no content
+	includesContent bool
+}
+
+// location describes where the backup set is stored
+func (s *restoreSource) location() string {
+	return backupSetLocation(s.claimName, s.s3, s.name)
+}
+
+// backupSetLocation describes where a backup set is stored. The prefix of s3
+// is taken as is.
+func backupSetLocation(claimName string, s3 *v1.S3Destination, name string) string {
+	if s3 != nil {
+		return "s3://" + s3.Bucket + "/" + s3.Prefix + "/" + name + "/"
+	}
+	return "pvc/" + claimName + "/" + name + "/"
+}
+
+// jobForRestore creates the Job running a stage of a restore. Backup sets in
+// S3 are downloaded by an init container first, those on a backup volume are
+// read in place.
+func (r *WordpressReconciler) jobForRestore(cr *v1.Wordpress, restore *v1.WordpressRestore, src *restoreSource, stage string) (*batchv1.Job, error) {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "BACKUP_NAME",
+			Value: src.name,
+		},
+	}
+	backupMount := corev1.VolumeMount{
+		Name:      "backup-storage",
+		MountPath: "/backup",
+	}
+	backupVolume := corev1.Volume{Name: "backup-storage"}
+
+	spec := corev1.PodSpec{
+		RestartPolicy: corev1.RestartPolicyOnFailure,
+	}
+	if src.s3 != nil {
+		backupVolume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+		spec.InitContainers = []corev1.Container{{
+			Name:         "fetch",
+			Image:        s3Image(src.s3),
+			Command:      []string{"bash", "-c", restoreFetchScript},
+			Env:          append(s3Env(src.s3), env...),
+			VolumeMounts: []corev1.VolumeMount{backupMount},
+		}}
+	} else {
+		backupMount.ReadOnly = true
+		backupVolume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: src.claimName,
+			ReadOnly:  true,
+		}
+	}
+	spec.Volumes = []corev1.Volume{backupVolume}
+
+	switch stage {
+	case restoreDatabaseStage:
+		spec.Containers = []corev1.Container{{
+			Name:    "restore-database",
+			Image:   backupImage(cr),
+			Command: []string{"bash", "-c", restoreDatabaseScript},
+			Env: append([]corev1.EnvVar{
+				{
+					Name:  "MYSQL_HOST",
+					Value: mysqlPrimaryHost(cr),
+				},
+				secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+			}, env...),
+			VolumeMounts: []corev1.VolumeMount{backupMount},
+		}}
+	case restoreContentStage:
+		spec.Containers = []corev1.Container{{
+			Name:    "restore-content",
+			Image:   wordpressImage(cr),
+			Command: []string{"sh", "-c", restoreContentScript},
+			Env:     env,
+			VolumeMounts: []corev1.VolumeMount{
+				backupMount,
+				{
+					Name:      "wordpress-persistent-storage",
+					MountPath: "/var/www/html",
+				},
+			},
+		}}
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name: "wordpress-persistent-storage",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: wordpressPVCName(cr),
+				},
+			},
+		})
+	}
+
+	backoffLimit := int32(3)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      restoreJobName(restore, stage),
+			Namespace: restore.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: spec,
+			},
+		},
+	}
+
+	applyImagePolicy(cr, &job.Spec.Template.Spec)
+	applyJobOverrides(cr, &job.Spec.Template.Spec)
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &job.Spec.Template.ObjectMeta)
+
+	if err := controllerutil.SetControllerReference(restore, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
@@ -0,0 +1,460 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// credentialRotationAnnotation is set on the MySQL Secret while a rotation is
+// in progress. Its value identifies the rotation and names its Job.
+const credentialRotationAnnotation = "wordpress.gopkg.blogpost.com/credential-rotation"
+
+// credentialDiscardAnnotation is set on the MySQL Secret once the new
+// passwords are current, until the retained ones have been discarded. Its
+// value is the time of the rotation in Unix seconds and names the Job.
+const credentialDiscardAnnotation = "wordpress.gopkg.blogpost.com/credential-discard"
+
+// credentialsRotatedAtAnnotation is set on the pod templates of the workloads
+// so that they roll when the passwords they read from Secrets change
+const credentialsRotatedAtAnnotation = "wordpress.gopkg.blogpost.com/credentials-rotated-at"
+
+// nextPasswordKey is the key a new password is staged under until the
+// database has accepted it
+func nextPasswordKey(key string) string {
+	return key + "-next"
+}
+
+// rotatedPasswords returns the Secret keys of the passwords the operator
+// generated, and may therefore rotate. Passwords from credentialsSecretRef
+// belong to the user.
+func rotatedPasswords(cr *v1.Wordpress, creds *dbCredentials) []corev1.SecretKeySelector {
+	var keys []corev1.SecretKeySelector
+	if cr.Spec.CredentialsSecretRef == nil {
+		keys = append(keys, creds.rootPassword)
+	}
+	if creds.generatesWordpressUser(cr) {
+		keys = append(keys, creds.password)
+	}
+	return keys
+}
+
+// credentialRotationDue reports whether a rotation was requested, and if not,
+// how long until the next scheduled one. A zero duration means none is
+// scheduled.
+func credentialRotationDue(cr *v1.Wordpress, now time.Time) (bool, time.Duration) {
+	if request := cr.Annotations[v1.RotateCredentialsAnnotation]; request != "" && request != cr.Status.ObservedRotationRequest {
+		return true, 0
+	}
+	if cr.Spec.RotationInterval == nil {
+		return false, 0
+	}
+
+	last := cr.CreationTimestamp.Time
+	if cr.Status.LastCredentialRotation != nil {
+		last = cr.Status.LastCredentialRotation.Time
+	}
+	next := last.Add(cr.Spec.RotationInterval.Duration)
+	if !now.Before(next) {
+		return true, 0
+	}
+	return false, next.Sub(now)
+}
+
+// mysqlCredentialRotationScript changes the passwords on the primary to the
+// staged ones. The current passwords are retained as secondary passwords, so
+// pods that have not restarted yet can still connect. A run that finds a
+// password already changed leaves it alone, which makes the Job safe to retry.
+const mysqlCredentialRotationScript = `set -e
+until mysqladmin ping -h "$MYSQL_HOST" --silent; do sleep 2; done
+can_login() { MYSQL_PWD="$2" mysql -h "$MYSQL_HOST" -u "$1" -e "SELECT 1" >/dev/null 2>&1; }
+quote() { printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e "s/'/''/g"; }
+
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+sql=""
+if [ -n "$MYSQL_ROOT_PASSWORD_NEXT" ]; then
+  if can_login root "$MYSQL_ROOT_PASSWORD_NEXT"; then
+    export MYSQL_PWD="$MYSQL_ROOT_PASSWORD_NEXT"
+  else
+    password=$(quote "$MYSQL_ROOT_PASSWORD_NEXT")
+    sql="$sql ALTER USER IF EXISTS 'root'@'%' IDENTIFIED BY '$password' RETAIN CURRENT PASSWORD;"
+    sql="$sql ALTER USER IF EXISTS 'root'@'localhost' IDENTIFIED BY '$password' RETAIN CURRENT PASSWORD;"
+  fi
+fi
+if [ -n "$WORDPRESS_DB_PASSWORD_NEXT" ] && ! can_login "$WORDPRESS_DB_USER" "$WORDPRESS_DB_PASSWORD_NEXT"; then
+  user=$(quote "$WORDPRESS_DB_USER")
+  password=$(quote "$WORDPRESS_DB_PASSWORD_NEXT")
+  sql="$sql ALTER USER '$user'@'%' IDENTIFIED BY '$password' RETAIN CURRENT PASSWORD;"
+fi
+if [ -n "$sql" ]; then
+  mysql -h "$MYSQL_HOST" -u root -e "$sql"
+fi
+`
+
+// mysqlDiscardOldPasswordsScript drops the passwords the rotation retained,
+// so that the rotated out ones stop working. It only runs once every
+// workload connects with the current passwords.
+const mysqlDiscardOldPasswordsScript = `set -e
+until mysqladmin ping -h "$MYSQL_HOST" --silent; do sleep 2; done
+quote() { printf '%s' "$1" | sed -e 's/\\/\\\\/g' -e "s/'/''/g"; }
+
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+user=$(quote "$WORDPRESS_DB_USER")
+mysql -h "$MYSQL_HOST" -u root -e "ALTER USER IF EXISTS 'root'@'%' DISCARD OLD PASSWORD;
+  ALTER USER IF EXISTS 'root'@'localhost' DISCARD OLD PASSWORD;
+  ALTER USER IF EXISTS '$user'@'%' DISCARD OLD PASSWORD;"
+`
+
+// jobForCredentialRotation creates the Job applying the staged passwords
+func (r *WordpressReconciler) jobForCredentialRotation(cr *v1.Wordpress, creds *dbCredentials, rotation string) *batchv1.Job {
+	env := []corev1.EnvVar{
+		{
+			Name:  "MYSQL_HOST",
+			Value: mysqlPrimaryHost(cr),
+		},
+		secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+		secretEnv("WORDPRESS_DB_USER", creds.username),
+	}
+	for _, key := range rotatedPasswords(cr, creds) {
+		name := "WORDPRESS_DB_PASSWORD_NEXT"
+		if key == creds.rootPassword {
+			name = "MYSQL_ROOT_PASSWORD_NEXT"
+		}
+		env = append(env, secretEnv(name, secretKey(key.Name, nextPasswordKey(key.Key))))
+	}
+	return r.jobForCredentials(cr, mysqlName(cr)+"-rotate-"+rotation, "rotate-credentials", mysqlCredentialRotationScript, env)
+}
+
+// jobForCredentialDiscard creates the Job discarding the retained passwords
+// once the workloads use the promoted ones
+func (r *WordpressReconciler) jobForCredentialDiscard(cr *v1.Wordpress, creds *dbCredentials, rotation string) *batchv1.Job {
+	env := []corev1.EnvVar{
+		{
+			Name:  "MYSQL_HOST",
+			Value: mysqlPrimaryHost(cr),
+		},
+		secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+		secretEnv("WORDPRESS_DB_USER", creds.username),
+	}
+	return r.jobForCredentials(cr, mysqlName(cr)+"-discard-"+rotation, "discard-old-passwords", mysqlDiscardOldPasswordsScript, env)
+}
+
+// jobForCredentials creates a Job of a credential rotation running a script
+// against the primary
+func (r *WordpressReconciler) jobForCredentials(cr *v1.Wordpress, name, container, script string, env []corev1.EnvVar) *batchv1.Job {
+	labels := map[string]string{
+		"app": cr.Name,
+	}
+
+	backoffLimit := int32(6)
+	ttl := int32(3600)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cr.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    container,
+						Image:   mysqlImage(cr),
+						Command: []string{"bash", "-c", script},
+						Env:     env,
+					}},
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+				},
+			},
+		},
+	}
+
+	applyImagePolicy(cr, &job.Spec.Template.Spec)
+	applyJobOverrides(cr, &job.Spec.Template.Spec)
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &job.Spec.Template.ObjectMeta)
+
+	controllerutil.SetControllerReference(cr, job, r.Scheme)
+	return job
+}
+
+// ensureCredentialRotation rotates the generated passwords when a rotation is
+// due. It stages new passwords next to the current ones, has a Job change them
+// in the database, and only then makes them current in the Secrets. The
+// workloads roll once LastCredentialRotation changes, after which a second
+// Job discards the old passwords.
+func (r *WordpressReconciler) ensureCredentialRotation(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
+	ctx := context.TODO()
+
+	creds, err := r.databaseCredentials(wordpress)
+	if err != nil {
+		return nil, err
+	}
+	mysqlSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: mysqlSecretName(wordpress), Namespace: wordpress.Namespace}, mysqlSecret); err != nil {
+		return nil, err
+	}
+
+	if rotatedAt, pending := mysqlSecret.Annotations[credentialDiscardAnnotation]; pending {
+		return r.ensureOldPasswordsDiscarded(request, wordpress, creds, mysqlSecret, rotatedAt)
+	}
+
+	rotation, inProgress := mysqlSecret.Annotations[credentialRotationAnnotation]
+	if !inProgress {
+		// When it is not due yet, requeueForSchedules comes back for it
+		if due, _ := credentialRotationDue(wordpress, time.Now()); !due {
+			return nil, nil
+		}
+
+		keys := rotatedPasswords(wordpress, creds)
+		if len(keys) == 0 {
+			// Nothing was rotated, so the workloads are not rolled either
+			r.Log.Info("No generated passwords to rotate, the credentials Secret is managed by the user",
+				"Secret.Namespace", wordpress.Namespace, "Secret.Name", wordpress.Spec.CredentialsSecretRef.Name)
+			wordpress.Status.ObservedRotationRequest = wordpress.Annotations[v1.RotateCredentialsAnnotation]
+			return nil, nil
+		}
+
+		// Stage the new passwords. The MySQL Secret is written last since its
+		// annotation marks the rotation as started.
+		rotation = strconv.FormatInt(time.Now().Unix(), 10)
+		for _, key := range keys {
+			if err := r.stagePassword(ctx, wordpress.Namespace, key); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.Client.Get(ctx, types.NamespacedName{Name: mysqlSecret.Name, Namespace: mysqlSecret.Namespace}, mysqlSecret); err != nil {
+			return nil, err
+		}
+		if mysqlSecret.Annotations == nil {
+			mysqlSecret.Annotations = map[string]string{}
+		}
+		mysqlSecret.Annotations[credentialRotationAnnotation] = rotation
+		r.Log.Info("Starting credential rotation", "Secret.Namespace", mysqlSecret.Namespace, "Secret.Name", mysqlSecret.Name)
+		if err := r.Client.Update(ctx, mysqlSecret); err != nil {
+			return nil, err
+		}
+	}
+
+	job := r.jobForCredentialRotation(wordpress, creds, rotation)
+	if result, err := r.ensureJob(request, wordpress, job); result != nil || err != nil {
+		return result, err
+	}
+	found := &batchv1.Job{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found); err != nil {
+		return nil, err
+	}
+
+	if found.Status.Succeeded == 0 {
+		for _, cond := range found.Status.Conditions {
+			if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+				r.Log.Error(fmt.Errorf("%s", cond.Message), "Credential rotation failed, retrying", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+				if err := r.Client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+					return nil, err
+				}
+				return &ctrl.Result{RequeueAfter: time.Minute}, nil
+			}
+		}
+		return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
+
+	// The database accepted the new passwords, make them current
+	for _, key := range rotatedPasswords(wordpress, creds) {
+		if err := r.promotePassword(ctx, wordpress.Namespace, key); err != nil {
+			return nil, err
+		}
+	}
+	// The status only gets written at the end of the reconcile, so the
+	// Secret keeps the time of the rotation until the old passwords are gone
+	rotatedAt := metav1.Now()
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: mysqlSecret.Name, Namespace: mysqlSecret.Namespace}, mysqlSecret); err != nil {
+		return nil, err
+	}
+	delete(mysqlSecret.Annotations, credentialRotationAnnotation)
+	mysqlSecret.Annotations[credentialDiscardAnnotation] = strconv.FormatInt(rotatedAt.Unix(), 10)
+	if err := r.Client.Update(ctx, mysqlSecret); err != nil {
+		return nil, err
+	}
+
+	r.Log.Info("Rotated database credentials", "Wordpress.Namespace", wordpress.Namespace, "Wordpress.Name", wordpress.Name)
+	r.recordCredentialRotation(wordpress, rotatedAt)
+	// Roll the workloads onto the new passwords
+	return &ctrl.Result{Requeue: true}, nil
+}
+
+// ensureOldPasswordsDiscarded waits for the workloads and the backups to
+// connect with the promoted passwords, then has a Job discard the ones the
+// rotation retained. Until then the rotated out passwords keep working.
+func (r *WordpressReconciler) ensureOldPasswordsDiscarded(request ctrl.Request, wordpress *v1.Wordpress, creds *dbCredentials, mysqlSecret *corev1.Secret, rotation string) (*ctrl.Result, error) {
+	ctx := context.TODO()
+
+	seconds, err := strconv.ParseInt(rotation, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s annotation on Secret %s: %w", credentialDiscardAnnotation, mysqlSecret.Name, err)
+	}
+	// When the status write of the rotation was lost, the pod templates still
+	// carry the time of the one before. Record it again, the next reconcile
+	// rolls the workloads onto the new passwords.
+	if last := wordpress.Status.LastCredentialRotation; last == nil || last.Unix() != seconds {
+		r.Log.Info("Recording the last credential rotation again", "Wordpress.Namespace", wordpress.Namespace, "Wordpress.Name", wordpress.Name)
+		r.recordCredentialRotation(wordpress, metav1.Unix(seconds, 0))
+		return &ctrl.Result{Requeue: true}, nil
+	}
+
+	rolled, err := r.credentialsRolledOut(ctx, wordpress)
+	if err != nil {
+		return nil, err
+	}
+	if !rolled {
+		// The rollouts of the Deployment and the StatefulSet trigger a new
+		// reconcile, backups finishing too
+		r.Log.Info("Waiting for the workloads to use the rotated passwords", "Wordpress.Namespace", wordpress.Namespace, "Wordpress.Name", wordpress.Name)
+		return nil, nil
+	}
+
+	job := r.jobForCredentialDiscard(wordpress, creds, rotation)
+	if result, err := r.ensureJob(request, wordpress, job); result != nil || err != nil {
+		return result, err
+	}
+	found := &batchv1.Job{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found); err != nil {
+		return nil, err
+	}
+	if found.Status.Succeeded == 0 {
+		for _, cond := range found.Status.Conditions {
+			if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+				r.Log.Error(fmt.Errorf("%s", cond.Message), "Discarding old passwords failed, retrying", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+				if err := r.Client.Delete(ctx, found); err != nil && !errors.IsNotFound(err) {
+					return nil, err
+				}
+				return &ctrl.Result{RequeueAfter: time.Minute}, nil
+			}
+		}
+		return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
+
+	delete(mysqlSecret.Annotations, credentialDiscardAnnotation)
+	if err := r.Client.Update(ctx, mysqlSecret); err != nil {
+		return nil, err
+	}
+	r.Log.Info("Discarded old database passwords", "Wordpress.Namespace", wordpress.Namespace, "Wordpress.Name", wordpress.Name)
+	return nil, nil
+}
+
+// credentialsRolledOut reports whether every pod connecting to the database
+// was started with the current passwords: the WordPress Deployment and the
+// MySQL StatefulSet finished rolling onto the pod template of the last
+// rotation, and no backup started before it is still running
+func (r *WordpressReconciler) credentialsRolledOut(ctx context.Context, cr *v1.Wordpress) (bool, error) {
+	rotatedAt := credentialsRotatedAt(cr)
+
+	dep := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: wordpressName(cr), Namespace: cr.Namespace}, dep)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if err == nil {
+		if dep.Spec.Template.Annotations[credentialsRotatedAtAnnotation] != rotatedAt[credentialsRotatedAtAnnotation] ||
+			dep.Status.ObservedGeneration < dep.Generation ||
+			dep.Status.UpdatedReplicas != dep.Status.Replicas {
+			return false, nil
+		}
+	}
+
+	sts := &appsv1.StatefulSet{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: mysqlName(cr), Namespace: cr.Namespace}, sts)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	if err == nil {
+		if sts.Spec.Template.Annotations[credentialsRotatedAtAnnotation] != rotatedAt[credentialsRotatedAtAnnotation] ||
+			sts.Status.ObservedGeneration < sts.Generation ||
+			sts.Status.CurrentRevision != sts.Status.UpdateRevision ||
+			sts.Status.UpdatedReplicas != sts.Status.Replicas {
+			return false, nil
+		}
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := r.Client.List(ctx, jobs, client.InNamespace(cr.Namespace), client.MatchingLabels{backupJobLabel: cr.Name}); err != nil {
+		return false, err
+	}
+	for _, job := range jobs.Items {
+		if job.Status.Active > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// recordCredentialRotation notes a finished rotation in the status
+func (r *WordpressReconciler) recordCredentialRotation(wordpress *v1.Wordpress, rotatedAt metav1.Time) {
+	wordpress.Status.LastCredentialRotation = &rotatedAt
+	wordpress.Status.ObservedRotationRequest = wordpress.Annotations[v1.RotateCredentialsAnnotation]
+}
+
+// stagePassword generates a new password next to the current one, unless an
+// earlier attempt already did
+func (r *WordpressReconciler) stagePassword(ctx context.Context, namespace string, key corev1.SecretKeySelector) error {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: key.Name, Namespace: namespace}, secret); err != nil {
+		return err
+	}
+	if _, ok := secret.Data[nextPasswordKey(key.Key)]; ok {
+		return nil
+	}
+
+	password, err := generateRandomPassword()
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[nextPasswordKey(key.Key)] = []byte(password)
+	return r.Client.Update(ctx, secret)
+}
+
+// promotePassword replaces the current password with the staged one
+func (r *WordpressReconciler) promotePassword(ctx context.Context, namespace string, key corev1.SecretKeySelector) error {
+	secret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: key.Name, Namespace: namespace}, secret); err != nil {
+		return err
+	}
+	next, ok := secret.Data[nextPasswordKey(key.Key)]
+	if !ok {
+		return nil
+	}
+
+	secret.Data[key.Key] = next
+	delete(secret.Data, nextPasswordKey(key.Key))
+	return r.Client.Update(ctx, secret)
+}
+
+// credentialsRotatedAt returns the pod template annotations that roll a
+// workload after a rotation
+func credentialsRotatedAt(cr *v1.Wordpress) map[string]string {
+	if cr.Status.LastCredentialRotation == nil {
+		return nil
+	}
+	return map[string]string{
+		credentialsRotatedAtAnnotation: cr.Status.LastCredentialRotation.UTC().Format(time.RFC3339),
+	}
+}
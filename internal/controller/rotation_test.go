@@ -0,0 +1,99 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wordpressv1alpha1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+)
+
+var _ = Describe("Credential rotation", func() {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newWordpress := func() *wordpressv1alpha1.Wordpress {
+		return &wordpressv1alpha1.Wordpress{
+			ObjectMeta: metav1.ObjectMeta{
+				CreationTimestamp: metav1.NewTime(created),
+			},
+		}
+	}
+
+	It("should not rotate without an interval or a request", func() {
+		due, wait := credentialRotationDue(newWordpress(), created.Add(365*24*time.Hour))
+		Expect(due).To(BeFalse())
+		Expect(wait).To(BeZero())
+	})
+
+	It("should rotate once per requested annotation value", func() {
+		cr := newWordpress()
+		cr.Annotations = map[string]string{wordpressv1alpha1.RotateCredentialsAnnotation: "2024-02-01"}
+
+		due, _ := credentialRotationDue(cr, created)
+		Expect(due).To(BeTrue())
+
+		cr.Status.ObservedRotationRequest = "2024-02-01"
+		due, _ = credentialRotationDue(cr, created)
+		Expect(due).To(BeFalse())
+	})
+
+	It("should rotate when the interval has passed since the last rotation", func() {
+		cr := newWordpress()
+		cr.Spec.RotationInterval = &metav1.Duration{Duration: 90 * 24 * time.Hour}
+
+		due, wait := credentialRotationDue(cr, created.Add(24*time.Hour))
+		Expect(due).To(BeFalse())
+		Expect(wait).To(Equal(89 * 24 * time.Hour))
+
+		due, _ = credentialRotationDue(cr, created.Add(90*24*time.Hour))
+		Expect(due).To(BeTrue())
+
+		last := metav1.NewTime(created.Add(90 * 24 * time.Hour))
+		cr.Status.LastCredentialRotation = &last
+		due, _ = credentialRotationDue(cr, created.Add(91*24*time.Hour))
+		Expect(due).To(BeFalse())
+	})
+
+	It("should record a rotation again when its status write was lost", func() {
+		reconciler := &WordpressReconciler{}
+		cr := newWordpress()
+		before := metav1.NewTime(created)
+		cr.Status.LastCredentialRotation = &before
+
+		// The Secret recorded the rotation, the status still has the one before
+		rotatedAt := created.Add(90 * 24 * time.Hour)
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					credentialDiscardAnnotation: strconv.FormatInt(rotatedAt.Unix(), 10),
+				},
+			},
+		}
+		result, err := reconciler.ensureOldPasswordsDiscarded(reconcile.Request{}, cr, &dbCredentials{}, secret, secret.Annotations[credentialDiscardAnnotation])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Requeue).To(BeTrue())
+		Expect(cr.Status.LastCredentialRotation.Time).To(BeTemporally("==", rotatedAt))
+		Expect(credentialsRotatedAt(cr)).To(HaveKeyWithValue(credentialsRotatedAtAnnotation, rotatedAt.Format(time.RFC3339)))
+	})
+})
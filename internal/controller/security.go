@@ -0,0 +1,167 @@
+package controller
+
+import (
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Users of the images. WordPress and the Jobs run as www-data so that they
+// share the content volume, MySQL as the mysql user owning its data.
+const (
+	wwwDataID = int64(33)
+	mysqlID   = int64(999)
+)
+
+// databaseClientLabel marks the pods the database NetworkPolicy lets
+// connect to MySQL
+const databaseClientLabel = "wordpress.gopkg.blogpost.com/database-client"
+
+// podSecurityContext returns a pod security context meeting the restricted
+// Pod Security Standard for the given user
+func podSecurityContext(id int64) *corev1.PodSecurityContext {
+	runAsNonRoot := true
+	onRootMismatch := corev1.FSGroupChangeOnRootMismatch
+	return &corev1.PodSecurityContext{
+		RunAsNonRoot:        &runAsNonRoot,
+		RunAsUser:           &id,
+		RunAsGroup:          &id,
+		FSGroup:             &id,
+		FSGroupChangePolicy: &onRootMismatch,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// wordpressPodSecurityContext lets the web server of an unprivileged
+// WordPress pod listen on port 80. The sysctl is namespaced to the pod and
+// allowed by every Pod Security Standard.
+func wordpressPodSecurityContext() *corev1.PodSecurityContext {
+	sc := podSecurityContext(wwwDataID)
+	sc.Sysctls = []corev1.Sysctl{{
+		Name:  "net.ipv4.ip_unprivileged_port_start",
+		Value: "0",
+	}}
+	return sc
+}
+
+// containerSecurityContext returns a container security context meeting the
+// restricted Pod Security Standard
+func containerSecurityContext(readOnlyRootFilesystem bool) *corev1.SecurityContext {
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// applySecurityContext sets the security context of a pod spec and of each
+// of its containers, the overrides of the spec replacing the defaults
+func applySecurityContext(spec *corev1.PodSpec, o *v1.PodTemplateOverrides, pod *corev1.PodSecurityContext, container *corev1.SecurityContext) {
+	if o != nil && o.SecurityContext != nil {
+		pod = o.SecurityContext
+	}
+	if o != nil && o.ContainerSecurityContext != nil {
+		container = o.ContainerSecurityContext
+	}
+	spec.SecurityContext = pod.DeepCopy()
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].SecurityContext = container.DeepCopy()
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].SecurityContext = container.DeepCopy()
+	}
+}
+
+// applyMysqlSecurityContext runs the MySQL pods unprivileged with read-only
+// root file systems. mysqld writes its socket to /var/run/mysqld, and the
+// scripts write temporary files.
+func applyMysqlSecurityContext(cr *v1.Wordpress, spec *corev1.PodSpec) {
+	applySecurityContext(spec, podOverrides(cr, "mysql"), podSecurityContext(mysqlID), containerSecurityContext(true))
+	spec.Volumes = append(spec.Volumes, scratchVolume("tmp"), scratchVolume("mysql-run"))
+	mountScratch(spec, "tmp", "/tmp")
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      "mysql-run",
+		MountPath: "/var/run/mysqld",
+	})
+}
+
+// applyWordpressSecurityContext runs the WordPress pods unprivileged with
+// read-only root file systems. Besides temporary files apache writes its
+// pid and lock files, nginx its configuration, cache and pid file.
+func applyWordpressSecurityContext(cr *v1.Wordpress, spec *corev1.PodSpec) {
+	applySecurityContext(spec, podOverrides(cr, "frontend"), wordpressPodSecurityContext(), containerSecurityContext(true))
+	spec.Volumes = append(spec.Volumes, scratchVolume("tmp"))
+	mountScratch(spec, "tmp", "/tmp")
+
+	// apache runs in the WordPress container, nginx next to it
+	scratch := []corev1.VolumeMount{
+		{Name: "apache-run", MountPath: "/var/run/apache2"},
+		{Name: "apache-lock", MountPath: "/var/lock/apache2"},
+	}
+	if wordpressVariant(cr) == v1.VariantFPM {
+		scratch = []corev1.VolumeMount{
+			{Name: "nginx-conf", MountPath: "/etc/nginx/conf.d"},
+			{Name: "nginx-cache", MountPath: "/var/cache/nginx"},
+			{Name: "nginx-run", MountPath: "/var/run"},
+		}
+	}
+	container := &spec.Containers[len(spec.Containers)-1]
+	for _, mount := range scratch {
+		spec.Volumes = append(spec.Volumes, scratchVolume(mount.Name))
+		container.VolumeMounts = append(container.VolumeMounts, mount)
+	}
+}
+
+// mountScratch mounts a scratch volume into every container of a pod spec
+func mountScratch(spec *corev1.PodSpec, name, path string) {
+	mount := corev1.VolumeMount{
+		Name:      name,
+		MountPath: path,
+	}
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].VolumeMounts = append(spec.InitContainers[i].VolumeMounts, mount)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, mount)
+	}
+}
+
+// applyJobSecurityContext applies the security context of the Jobs to the
+// pod spec of a Job. The images of the Jobs write to their file systems.
+// The Jobs cleaning up after a backup may outlive the instance, cr is nil
+// then.
+func applyJobSecurityContext(cr *v1.Wordpress, spec *corev1.PodSpec) {
+	var overrides *v1.PodTemplateOverrides
+	if cr != nil {
+		overrides = podOverrides(cr, "jobs")
+	}
+	applySecurityContext(spec, overrides, podSecurityContext(wwwDataID), containerSecurityContext(false))
+}
+
+// scratchVolume returns an empty directory for a path a container with a
+// read-only root file system writes to
+func scratchVolume(name string) corev1.Volume {
+	return corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{},
+		},
+	}
+}
+
+// allowDatabaseAccess labels the pods of a template as clients of the
+// database of the instance. The labels are copied, they may be shared with
+// a selector.
+func allowDatabaseAccess(cr *v1.Wordpress, template *metav1.ObjectMeta) {
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+	labels[databaseClientLabel] = cr.Name
+	template.Labels = labels
+}
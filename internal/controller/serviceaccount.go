@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"context"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// serviceAccountTiers are the tiers getting a ServiceAccount of their own
+var serviceAccountTiers = []string{"wordpress", "mysql", "jobs"}
+
+// serviceAccountSpec returns the ServiceAccount settings of the spec for a
+// tier, if any
+func serviceAccountSpec(cr *v1.Wordpress, tier string) *v1.ServiceAccountSpec {
+	if cr.Spec.ServiceAccounts == nil {
+		return nil
+	}
+	switch tier {
+	case "wordpress":
+		return cr.Spec.ServiceAccounts.Wordpress
+	case "mysql":
+		return cr.Spec.ServiceAccounts.Mysql
+	default:
+		return cr.Spec.ServiceAccounts.Jobs
+	}
+}
+
+// automountToken reports whether the pods of a tier get an API token
+func automountToken(cr *v1.Wordpress, tier string) bool {
+	if spec := serviceAccountSpec(cr, tier); spec != nil && spec.AutomountServiceAccountToken != nil {
+		return *spec.AutomountServiceAccountToken
+	}
+	return false
+}
+
+// Creates the ServiceAccount of a tier
+func (r *WordpressReconciler) serviceAccountFor(cr *v1.Wordpress, tier string) *corev1.ServiceAccount {
+	automount := automountToken(cr, tier)
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      serviceAccountName(cr, tier),
+			Namespace: cr.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		AutomountServiceAccountToken: &automount,
+	}
+	if spec := serviceAccountSpec(cr, tier); spec != nil {
+		sa.Annotations = spec.Annotations
+	}
+
+	controllerutil.SetControllerReference(cr, sa, r.Scheme)
+	return sa
+}
+
+// applyServiceAccount runs the pods of a pod spec under the ServiceAccount
+// of a tier. The token setting is repeated on the pods so that it holds
+// whatever the ServiceAccount says.
+func applyServiceAccount(cr *v1.Wordpress, tier string, spec *corev1.PodSpec) {
+	automount := automountToken(cr, tier)
+	spec.ServiceAccountName = serviceAccountName(cr, tier)
+	spec.AutomountServiceAccountToken = &automount
+}
+
+// ensureServiceAccounts creates the ServiceAccount of each tier
+func (r *WordpressReconciler) ensureServiceAccounts(request reconcile.Request, instance *v1.Wordpress) (*reconcile.Result, error) {
+	for _, tier := range serviceAccountTiers {
+		if result, err := r.ensureServiceAccount(request, instance, r.serviceAccountFor(instance, tier)); result != nil || err != nil {
+			return result, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *WordpressReconciler) ensureServiceAccount(_ reconcile.Request,
+	instance *v1.Wordpress,
+	sa *corev1.ServiceAccount,
+) (*reconcile.Result, error) {
+
+	found := &corev1.ServiceAccount{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      sa.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating a new ServiceAccount", "ServiceAccount.Namespace", sa.Namespace, "ServiceAccount.Name", sa.Name)
+		if err := r.Client.Create(context.TODO(), sa); err != nil {
+			r.Log.Error(err, "Failed to create new ServiceAccount", "ServiceAccount.Namespace", sa.Namespace, "ServiceAccount.Name", sa.Name)
+			return &reconcile.Result{}, err
+		}
+		return nil, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get ServiceAccount")
+		return &ctrl.Result{}, err
+	}
+
+	if err := checkOwnership(instance, found, "ServiceAccount"); err != nil {
+		r.Log.Error(err, "Refusing to adopt ServiceAccount")
+		return &ctrl.Result{}, err
+	}
+
+	// Annotations set by others, like the token controller, are left alone
+	labelsChanged := mergeLabels(&found.ObjectMeta, sa.Labels)
+	annotationsChanged := mergeAnnotations(&found.ObjectMeta, sa.Annotations)
+	if !labelsChanged && !annotationsChanged &&
+		equality.Semantic.DeepEqual(sa.AutomountServiceAccountToken, found.AutomountServiceAccountToken) {
+		return nil, nil
+	}
+
+	found.AutomountServiceAccountToken = sa.AutomountServiceAccountToken
+	r.Log.Info("Updating ServiceAccount", "ServiceAccount.Namespace", found.Namespace, "ServiceAccount.Name", found.Name)
+	if err := r.Client.Update(context.TODO(), found); err != nil {
+		r.Log.Error(err, "Failed to update ServiceAccount", "ServiceAccount.Namespace", found.Namespace, "ServiceAccount.Name", found.Name)
+		return &ctrl.Result{}, err
+	}
+	return nil, nil
+}
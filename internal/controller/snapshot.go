@@ -0,0 +1,381 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// VolumeSnapshots are handled as unstructured objects, so that the operator
+// neither depends on the external-snapshotter client nor fails to start on
+// clusters without the snapshot CRDs.
+var volumeSnapshotGVK = schema.GroupVersionKind{
+	Group:   "snapshot.storage.k8s.io",
+	Version: "v1",
+	Kind:    "VolumeSnapshot",
+}
+
+// volumeSnapshotsUnavailable explains why VolumeSnapshot backups cannot be taken
+const volumeSnapshotsUnavailable = "the cluster does not support VolumeSnapshots, the snapshot.storage.k8s.io CRDs are not installed"
+
+// tableLockScript holds a global read lock on the primary for as long as the
+// Job runs. The pod turns ready once the lock is taken, and the controller
+// deletes the Job once the snapshots are cut, which ends the session and
+// with it the lock. The lock is given up after LOCK_TIMEOUT seconds in any
+// case, so that a stuck backup does not keep the site read-only.
+const tableLockScript = `set -eo pipefail
+export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"
+until mysqladmin ping -h "$MYSQL_HOST" -u root --silent; do sleep 2; done
+mysql -h "$MYSQL_HOST" -u root <<SQL
+FLUSH TABLES WITH READ LOCK;
+system touch /tmp/locked
+SELECT SLEEP($LOCK_TIMEOUT);
+SQL
+`
+
+// tableLockTimeout is how many seconds the tables are locked for at most
+const tableLockTimeout = "300"
+
+// volumeSnapshotsAvailable reports whether the VolumeSnapshot CRDs are
+// installed. It is checked whenever it matters, so that snapshots can be
+// used as soon as the CRDs are installed.
+func volumeSnapshotsAvailable(c client.Client) (bool, error) {
+	_, err := c.RESTMapper().RESTMapping(volumeSnapshotGVK.GroupKind(), volumeSnapshotGVK.Version)
+	if meta.IsNoMatchError(err) || errors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// configuredBackupMethod returns the method the scheduled backups are
+// configured with
+func configuredBackupMethod(cr *v1.Wordpress) v1.BackupMethod {
+	if cr.Spec.Backup != nil && cr.Spec.Backup.Method != "" {
+		return cr.Spec.Backup.Method
+	}
+	return v1.BackupMethodDump
+}
+
+// scheduledBackupMethod returns the method scheduled backups are taken with.
+// They fall back to dumps on clusters without VolumeSnapshots, rather than
+// not being taken at all.
+func (r *WordpressReconciler) scheduledBackupMethod(cr *v1.Wordpress) (v1.BackupMethod, error) {
+	method := configuredBackupMethod(cr)
+	if method != v1.BackupMethodVolumeSnapshot {
+		return method, nil
+	}
+	available, err := volumeSnapshotsAvailable(r.Client)
+	if err != nil {
+		return "", err
+	}
+	if !available {
+		return v1.BackupMethodDump, nil
+	}
+	return method, nil
+}
+
+// isSnapshotBackup reports whether a backup is taken as VolumeSnapshots
+func isSnapshotBackup(b *v1.WordpressBackup) bool {
+	return b.Status.Method == v1.BackupMethodVolumeSnapshot
+}
+
+// volumeSnapshotClassName returns the class of the VolumeSnapshots, empty for
+// the default class
+func volumeSnapshotClassName(cr *v1.Wordpress) string {
+	if cr.Spec.Backup == nil {
+		return ""
+	}
+	return cr.Spec.Backup.VolumeSnapshotClassName
+}
+
+// recordVolumeSnapshots lists the VolumeSnapshots a backup is going to take
+// in its status
+func recordVolumeSnapshots(backup *v1.WordpressBackup) {
+	volumes := []string{v1.SnapshotVolumeDatabase}
+	if backupIncludesContent(backup) {
+		volumes = append(volumes, v1.SnapshotVolumeContent)
+	}
+
+	var locations []string
+	backup.Status.VolumeSnapshots = nil
+	for _, volume := range volumes {
+		name := volumeSnapshotName(backup, volume)
+		backup.Status.VolumeSnapshots = append(backup.Status.VolumeSnapshots, v1.BackupVolumeSnapshot{
+			Volume: volume,
+			Name:   name,
+		})
+		locations = append(locations, "volumesnapshot/"+name)
+	}
+	backup.Status.Location = strings.Join(locations, ",")
+}
+
+// jobForTableLock creates the Job locking the tables of the primary while
+// the VolumeSnapshots of a backup are taken
+func (r *WordpressReconciler) jobForTableLock(cr *v1.Wordpress, backup *v1.WordpressBackup) (*batchv1.Job, error) {
+	labels := backupJobLabels(cr)
+
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Trying again would lock the tables a second time, the backup fails
+	// instead
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tableLockJobName(backup),
+			Namespace: backup.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    "lock-tables",
+						Image:   backupImage(cr),
+						Command: []string{"bash", "-c", tableLockScript},
+						Env: []corev1.EnvVar{
+							{
+								Name:  "MYSQL_HOST",
+								Value: mysqlPrimaryHost(cr),
+							},
+							{
+								Name:  "LOCK_TIMEOUT",
+								Value: tableLockTimeout,
+							},
+							secretEnv("MYSQL_ROOT_PASSWORD", creds.rootPassword),
+						},
+						// Ready while the lock is held
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								Exec: &corev1.ExecAction{
+									Command: []string{"test", "-f", "/tmp/locked"},
+								},
+							},
+							PeriodSeconds: 1,
+						},
+					}},
+					RestartPolicy: corev1.RestartPolicyNever,
+				},
+			},
+		},
+	}
+
+	applyImagePolicy(cr, &job.Spec.Template.Spec)
+	applyJobOverrides(cr, &job.Spec.Template.Spec)
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &job.Spec.Template.ObjectMeta)
+
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// volumeSnapshotFor creates the VolumeSnapshot a backup takes of a volume of
+// the instance. It is owned by the backup and goes away with it.
+func (r *WordpressReconciler) volumeSnapshotFor(cr *v1.Wordpress, backup *v1.WordpressBackup, volume string) (*unstructured.Unstructured, error) {
+	claimName := wordpressPVCName(cr)
+	if volume == v1.SnapshotVolumeDatabase {
+		claimName = mysqlPrimaryPVCName(cr)
+	}
+
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claimName,
+		},
+	}
+	if class := volumeSnapshotClassName(cr); class != "" {
+		spec["volumeSnapshotClassName"] = class
+	}
+
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(volumeSnapshotGVK)
+	vs.SetName(volumeSnapshotName(backup, volume))
+	vs.SetNamespace(backup.Namespace)
+	vs.SetLabels(map[string]string{
+		"app":                  cr.Name,
+		v1.BackupInstanceLabel: cr.Name,
+	})
+	if err := unstructured.SetNestedMap(vs.Object, spec, "spec"); err != nil {
+		return nil, err
+	}
+
+	if err := controllerutil.SetControllerReference(backup, vs, r.Scheme); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// secretForSnapshotCredentials keeps the root password a VolumeSnapshot
+// backup was taken with. The database in the snapshot only knows that
+// password, instances cloned from the backup start out with it.
+func (r *WordpressReconciler) secretForSnapshotCredentials(cr *v1.Wordpress, backup *v1.WordpressBackup) (*corev1.Secret, error) {
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
+	source := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      creds.rootPassword.Name,
+		Namespace: cr.Namespace,
+	}, source)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotCredentialsSecretName(backup),
+			Namespace: backup.Namespace,
+			Labels: map[string]string{
+				"app": cr.Name,
+			},
+		},
+		Data: map[string][]byte{
+			mysqlRootPasswordKey: source.Data[creds.rootPassword.Key],
+		},
+	}
+
+	if err := controllerutil.SetControllerReference(backup, secret, r.Scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// getVolumeSnapshot returns a VolumeSnapshot, nil when it does not exist
+func getVolumeSnapshot(ctx context.Context, c client.Client, namespace, name string) (*unstructured.Unstructured, error) {
+	vs := &unstructured.Unstructured{}
+	vs.SetGroupVersionKind(volumeSnapshotGVK)
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, vs)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+// volumeSnapshotState is what the status of a VolumeSnapshot tells
+type volumeSnapshotState struct {
+	// cut is true once the snapshot is taken, the volume may change from then on
+	cut bool
+	// ready is true once volumes can be created from the snapshot
+	ready bool
+	// restoreSize is the smallest volume the snapshot can be restored to
+	restoreSize *resource.Quantity
+	// err is the last error taking the snapshot
+	err string
+}
+
+func volumeSnapshotStateOf(vs *unstructured.Unstructured) volumeSnapshotState {
+	var state volumeSnapshotState
+	creationTime, _, _ := unstructured.NestedString(vs.Object, "status", "creationTime")
+	state.cut = creationTime != ""
+	state.ready, _, _ = unstructured.NestedBool(vs.Object, "status", "readyToUse")
+	if size, ok, _ := unstructured.NestedString(vs.Object, "status", "restoreSize"); ok {
+		if q, err := resource.ParseQuantity(size); err == nil {
+			state.restoreSize = &q
+		}
+	}
+	state.err, _, _ = unstructured.NestedString(vs.Object, "status", "error", "message")
+	return state
+}
+
+// cloneSource returns the backup an instance is cloned from. It must be a
+// VolumeSnapshot backup that succeeded.
+func (r *WordpressReconciler) cloneSource(cr *v1.Wordpress) (*v1.WordpressBackup, error) {
+	name := cr.Spec.DataSource.BackupRef.Name
+	backup := &v1.WordpressBackup{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: cr.Namespace}, backup)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("WordpressBackup %q to clone from not found", name)
+	} else if err != nil {
+		return nil, err
+	}
+	if !isSnapshotBackup(backup) {
+		return nil, fmt.Errorf("WordpressBackup %q holds no VolumeSnapshots, restore it with a WordpressRestore instead", name)
+	}
+	if backup.Status.Phase != v1.BackupSucceeded {
+		return nil, fmt.Errorf("WordpressBackup %q to clone from has not succeeded", name)
+	}
+	return backup, nil
+}
+
+// clonedRootPassword returns the root password of the database an instance
+// is cloned from
+func (r *WordpressReconciler) clonedRootPassword(cr *v1.Wordpress) (string, error) {
+	backup, err := r.cloneSource(cr)
+	if err != nil {
+		return "", err
+	}
+	secret := &corev1.Secret{}
+	err = r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      snapshotCredentialsSecretName(backup),
+		Namespace: backup.Namespace,
+	}, secret)
+	if err != nil {
+		return "", err
+	}
+	password := string(secret.Data[mysqlRootPasswordKey])
+	if password == "" {
+		return "", fmt.Errorf("Secret %q has no root password", secret.Name)
+	}
+	return password, nil
+}
+
+// ensureClonedVolume creates a volume of a cloned instance from the snapshot
+// of that volume. Existing volumes are left alone, the backup is only needed
+// until the instance is set up. A volume the backup has no snapshot of
+// starts out empty.
+func (r *WordpressReconciler) ensureClonedVolume(cr *v1.Wordpress, pvc *corev1.PersistentVolumeClaim, volume string) error {
+	if cr.Spec.DataSource == nil {
+		return nil
+	}
+
+	found := &corev1.PersistentVolumeClaim{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, found)
+	if err == nil {
+		return nil
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+
+	backup, err := r.cloneSource(cr)
+	if err != nil {
+		return err
+	}
+	for _, snap := range backup.Status.VolumeSnapshots {
+		if snap.Volume != volume {
+			continue
+		}
+		apiGroup := volumeSnapshotGVK.Group
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &apiGroup,
+			Kind:     volumeSnapshotGVK.Kind,
+			Name:     snap.Name,
+		}
+		r.Log.Info("Creating PVC from VolumeSnapshot", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name,
+			"VolumeSnapshot.Name", snap.Name)
+		return r.Client.Create(context.TODO(), pvc)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// setCondition records a condition against the current generation of the CR
+func setCondition(cr *v1.Wordpress, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&cr.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: cr.Generation,
+	})
+}
+
+// readyReplicas returns the number of ready pods of a Deployment, or zero if
+// it cannot be read
+func (r *WordpressReconciler) readyReplicas(namespace, name string) int32 {
+	deployment := &appsv1.Deployment{}
+	err := r.Client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, deployment)
+	if err != nil {
+		return 0
+	}
+	return deployment.Status.ReadyReplicas
+}
+
+// summariseStatus derives the Ready condition and the phase from the tier
+// conditions and the outcome of the reconcile
+func summariseStatus(cr *v1.Wordpress, reconcileErr error) {
+	cr.Status.ObservedGeneration = cr.Generation
+
+	if reconcileErr != nil {
+		setCondition(cr, v1.ConditionReady, metav1.ConditionFalse, "ReconcileError", reconcileErr.Error())
+		cr.Status.Phase = v1.PhaseFailed
+		return
+	}
+
+	for _, condType := range []string{v1.ConditionMysqlReady, v1.ConditionDatabaseUserReady, v1.ConditionWordpressReady, v1.ConditionBackupScheduled} {
+		if condType == v1.ConditionBackupScheduled && !backupEnabled(cr) {
+			continue
+		}
+		if !meta.IsStatusConditionTrue(cr.Status.Conditions, condType) {
+			setCondition(cr, v1.ConditionReady, metav1.ConditionFalse, "Provisioning",
+				fmt.Sprintf("Waiting for %s", condType))
+			cr.Status.Phase = v1.PhaseProvisioning
+			return
+		}
+	}
+
+	setCondition(cr, v1.ConditionReady, metav1.ConditionTrue, "AllTiersReady", "WordPress and MySQL are up")
+	cr.Status.Phase = v1.PhaseReady
+}
+
+// updateStatus writes the status subresource if it changed during the reconcile
+func (r *WordpressReconciler) updateStatus(ctx context.Context, cr *v1.Wordpress, original *v1.WordpressStatus) error {
+	if equality.Semantic.DeepEqual(original, &cr.Status) {
+		return nil
+	}
+	if err := r.Client.Status().Update(ctx, cr); err != nil {
+		r.Log.Error(err, "Failed to update Wordpress status")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// wordpressVolume returns the spec of the WordPress content volume, which is
+// ReadWriteMany by default in Shared content mode
+func wordpressVolume(cr *v1.Wordpress) v1.VolumeSpec {
+	var vol v1.VolumeSpec
+	if cr.Spec.Storage != nil {
+		vol = cr.Spec.Storage.Wordpress
+	}
+	if len(vol.AccessModes) == 0 && contentMode(cr) == v1.ContentShared {
+		vol.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}
+	}
+	return vol
+}
+
+// mysqlVolume returns the spec of the volume of each MySQL pod
+func mysqlVolume(cr *v1.Wordpress) v1.VolumeSpec {
+	if cr.Spec.Storage == nil {
+		return v1.VolumeSpec{}
+	}
+	return cr.Spec.Storage.Mysql
+}
+
+// backupVolume returns the spec of the backup volume
+func backupVolume(cr *v1.Wordpress) v1.VolumeSpec {
+	if cr.Spec.Storage == nil {
+		return v1.VolumeSpec{}
+	}
+	return cr.Spec.Storage.Backup
+}
+
+// volumeResizeCheckInterval is how often volumes being expanded are checked on
+const volumeResizeCheckInterval = 30 * time.Second
+
+// volumeSize returns the requested size of a volume
+func volumeSize(vol v1.VolumeSpec) resource.Quantity {
+	if vol.Size != nil {
+		return *vol.Size
+	}
+	return resource.MustParse(v1.DefaultVolumeSize)
+}
+
+// applyVolumeSpec sets the requested size, storage class, access modes and
+// volume mode on a claim. The access modes of the claim are kept when the
+// spec leaves them empty.
+func applyVolumeSpec(pvc *corev1.PersistentVolumeClaim, vol v1.VolumeSpec) {
+	pvc.Spec.Resources.Requests = corev1.ResourceList{
+		corev1.ResourceStorage: volumeSize(vol),
+	}
+	pvc.Spec.StorageClassName = vol.StorageClassName
+	if len(vol.AccessModes) > 0 {
+		pvc.Spec.AccessModes = vol.AccessModes
+	}
+	pvc.Spec.VolumeMode = vol.VolumeMode
+}
+
+// mysqlVolumes returns the volumes the MySQL StatefulSet created from its
+// volume claim template, one for each pod it ran
+func (r *WordpressReconciler) mysqlVolumes(ctx context.Context, cr *v1.Wordpress) ([]corev1.PersistentVolumeClaim, error) {
+	pvcs := &corev1.PersistentVolumeClaimList{}
+	if err := r.Client.List(ctx, pvcs, client.InNamespace(cr.Namespace), client.MatchingLabels{"app": cr.Name}); err != nil {
+		return nil, err
+	}
+	prefix := "mysql-persistent-storage-" + mysqlName(cr) + "-"
+	var volumes []corev1.PersistentVolumeClaim
+	for _, pvc := range pvcs.Items {
+		ordinal, ok := strings.CutPrefix(pvc.Name, prefix)
+		if ok && isOrdinal(ordinal) {
+			volumes = append(volumes, pvc)
+		}
+	}
+	return volumes, nil
+}
+
+// isOrdinal reports whether s is the ordinal of a StatefulSet pod
+func isOrdinal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ensureClaimSize is ensureVolumeSize for the claim of the given name
+func (r *WordpressReconciler) ensureClaimSize(ctx context.Context, cr *v1.Wordpress, volume, claimName string, vol v1.VolumeSpec) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: claimName, Namespace: cr.Namespace}, pvc); err != nil {
+		return err
+	}
+	return r.ensureVolumeSize(ctx, cr, volume, pvc, vol)
+}
+
+// ensureVolumeSize grows a claim to the size the spec asks for, if its
+// storage class allows it, and reports how the expansion is going in the
+// status. Claims are never shrunk.
+func (r *WordpressReconciler) ensureVolumeSize(ctx context.Context, cr *v1.Wordpress, volume string, pvc *corev1.PersistentVolumeClaim, vol v1.VolumeSpec) error {
+	desired := volumeSize(vol)
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	status := v1.VolumeStatus{
+		ClaimName: pvc.Name,
+		Volume:    volume,
+		Requested: &desired,
+	}
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		status.Capacity = &capacity
+	}
+
+	switch desired.Cmp(requested) {
+	case -1:
+		status.Expansion = v1.VolumeExpansionUnsupported
+		status.Message = fmt.Sprintf("Volumes cannot shrink, the claim already requests %s", requested.String())
+		setVolumeStatus(cr, status)
+		return nil
+	case 1:
+		// A claim keeps the size it was created with until it is bound
+		if pvc.Status.Phase != corev1.ClaimBound {
+			status.Message = fmt.Sprintf("Waiting for the claim to be bound before growing it to %s", desired.String())
+			setVolumeStatus(cr, status)
+			return nil
+		}
+		if reason, err := r.expansionUnsupported(ctx, pvc); err != nil {
+			return err
+		} else if reason != "" {
+			status.Expansion = v1.VolumeExpansionUnsupported
+			status.Message = reason
+			setVolumeStatus(cr, status)
+			return nil
+		}
+		r.Log.Info("Expanding PVC", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name,
+			"From", requested.String(), "To", desired.String())
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = desired
+		if err := r.Client.Update(ctx, pvc); err != nil {
+			r.Log.Error(err, "Failed to expand PVC", "PVC.Namespace", pvc.Namespace, "PVC.Name", pvc.Name)
+			return err
+		}
+	}
+
+	// An unbound claim has no capacity yet and is created at the requested size
+	if status.Capacity != nil && status.Capacity.Cmp(desired) < 0 {
+		status.Expansion = v1.VolumeResizing
+		status.Message = fmt.Sprintf("Growing from %s to %s", status.Capacity.String(), desired.String())
+		for _, cond := range pvc.Status.Conditions {
+			if cond.Status != corev1.ConditionTrue {
+				continue
+			}
+			if cond.Type == corev1.PersistentVolumeClaimFileSystemResizePending {
+				status.Expansion = v1.VolumeFileSystemResizePending
+				status.Message = "The file system grows the next time a pod mounts the volume"
+			} else if strings.HasSuffix(string(cond.Type), "ResizeError") {
+				status.Message = cond.Message
+			}
+		}
+	}
+	setVolumeStatus(cr, status)
+	return nil
+}
+
+// expansionUnsupported explains why a claim cannot be expanded, or returns
+// an empty string if it can
+func (r *WordpressReconciler) expansionUnsupported(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (string, error) {
+	className := ""
+	if pvc.Spec.StorageClassName != nil {
+		className = *pvc.Spec.StorageClassName
+	}
+	if className == "" {
+		return "The claim has no storage class, only dynamically provisioned volumes can be expanded", nil
+	}
+	class := &storagev1.StorageClass{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: className}, class); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("Storage class %s not found", className), nil
+		}
+		return "", err
+	}
+	if class.AllowVolumeExpansion == nil || !*class.AllowVolumeExpansion {
+		return fmt.Sprintf("Storage class %s does not allow volume expansion", className), nil
+	}
+	return "", nil
+}
+
+// setVolumeStatus records the status of a volume in the status of the instance
+func setVolumeStatus(cr *v1.Wordpress, status v1.VolumeStatus) {
+	for i := range cr.Status.Volumes {
+		if cr.Status.Volumes[i].ClaimName == status.ClaimName {
+			cr.Status.Volumes[i] = status
+			return
+		}
+	}
+	cr.Status.Volumes = append(cr.Status.Volumes, status)
+}
+
+// volumesResizing reports whether a volume of the instance is being expanded
+func volumesResizing(cr *v1.Wordpress) bool {
+	for _, vol := range cr.Status.Volumes {
+		if vol.Expansion == v1.VolumeResizing || vol.Expansion == v1.VolumeFileSystemResizePending {
+			return true
+		}
+	}
+	return false
+}
@@ -4,7 +4,6 @@ import (
 	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -160,7 +159,7 @@ import (
 //		controllerutil.SetControllerReference(cr, dep, r.Scheme)
 //		return dep
 //	}
-func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) *appsv1.Deployment {
+func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) (*appsv1.Deployment, error) {
 
 	labels := map[string]string{
 		"app": cr.Name,
@@ -175,10 +174,19 @@ func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) *appsv1.D
 	if cr.Spec.Replicas != nil {
 		replicas = *cr.Spec.Replicas
 	}
+	// A restore stops WordPress while it overwrites the database and content
+	if restoreInProgress(cr) {
+		replicas = 0
+	}
+
+	creds, err := r.databaseCredentials(cr)
+	if err != nil {
+		return nil, err
+	}
 
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "wordpress",
+			Name:      wordpressName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
@@ -189,29 +197,19 @@ func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) *appsv1.D
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: matchLabels,
+					Labels:      matchLabels,
+					Annotations: credentialsRotatedAt(cr),
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{{
-						Image: "wordpress:5.4-apache",
+						Image: wordpressImage(cr),
 						Name:  "wordpress",
-						Env: []corev1.EnvVar{
+						Env: append([]corev1.EnvVar{
 							{
 								Name:  "WORDPRESS_DB_HOST",
-								Value: "wordpress-mysql",
+								Value: mysqlPrimaryHost(cr),
 							},
-							{
-								Name: "WORDPRESS_DB_PASSWORD",
-								ValueFrom: &corev1.EnvVarSource{
-									SecretKeyRef: &corev1.SecretKeySelector{
-										LocalObjectReference: corev1.LocalObjectReference{
-											Name: "mysql-root-password-secret", // Name of the Kubernetes Secret
-										},
-										Key: "password", // Key in the Secret
-									},
-								},
-							},
-						},
+						}, append(creds.wordpressDatabaseEnv(), wordpressConfigEnv(cr)...)...),
 						Ports: []corev1.ContainerPort{{
 							ContainerPort: 80,
 							Name:          "wordpress-port",
@@ -225,12 +223,8 @@ func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) *appsv1.D
 					}},
 					Volumes: []corev1.Volume{
 						{
-							Name: "wordpress-persistent-storage",
-							VolumeSource: corev1.VolumeSource{
-								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: "wp-pv-claim",
-								},
-							},
+							Name:         "wordpress-persistent-storage",
+							VolumeSource: contentVolumeSource(cr),
 						},
 					},
 				},
@@ -238,8 +232,67 @@ func (r *WordpressReconciler) deploymentForWordpress(cr *v1.Wordpress) *appsv1.D
 		},
 	}
 
+	// The fpm variant speaks FastCGI on 9000, nginx serves HTTP on 80 in
+	// front of it from the same document root
+	if wordpressVariant(cr) == v1.VariantFPM {
+		podSpec := &dep.Spec.Template.Spec
+		podSpec.Containers[0].Ports = []corev1.ContainerPort{{
+			ContainerPort: 9000,
+			Name:          "fpm",
+		}}
+		podSpec.Containers = append(podSpec.Containers, corev1.Container{
+			Image:   webServerImage(cr),
+			Name:    "nginx",
+			Command: []string{"sh", "-c", nginxFPMScript},
+			Ports: []corev1.ContainerPort{{
+				ContainerPort: 80,
+				Name:          "wordpress-port",
+			}},
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      "wordpress-persistent-storage",
+					MountPath: "/var/www/html",
+				},
+			},
+		})
+	}
+	wordpressContainer := &dep.Spec.Template.Spec.Containers[0]
+	applyProbes(wordpressContainer, wordpressProbes(wordpressContainer.Ports[0].ContainerPort), wordpressProbeOverrides(cr))
+	applyPodOverrides(&dep.Spec.Template.Spec, podOverrides(cr, "frontend"), &wordpressResources, spreadAcrossNodes(matchLabels))
+	applyWordpressSecurityContext(cr, &dep.Spec.Template.Spec)
+	applyServiceAccount(cr, "wordpress", &dep.Spec.Template.Spec)
+	allowDatabaseAccess(cr, &dep.Spec.Template.ObjectMeta)
+	applyImagePolicy(cr, &dep.Spec.Template.Spec)
+
 	controllerutil.SetControllerReference(cr, dep, r.Scheme)
-	return dep
+	return dep, nil
+}
+
+// wordpressConfigEnv returns the environment the official image builds
+// wp-config.php from, on top of the database settings. WORDPRESS_CONFIG_EXTRA
+// holds the PHP code added to it.
+func wordpressConfigEnv(cr *v1.Wordpress) []corev1.EnvVar {
+	env := contentEnv(cr)
+	extra := ""
+	if url := publicURL(cr); url != "" {
+		env = append(env, corev1.EnvVar{Name: "WP_HOME", Value: url})
+		extra += siteURLConfigScript
+	}
+	if !hasContentVolume(cr) {
+		extra += statelessConfigScript
+	}
+	if extra != "" {
+		env = append(env, corev1.EnvVar{Name: "WORDPRESS_CONFIG_EXTRA", Value: extra})
+	}
+	return env
+}
+
+// serviceType returns the type of the WordPress Service, NodePort by default
+func serviceType(cr *v1.Wordpress) corev1.ServiceType {
+	if cr.Spec.Service != nil && cr.Spec.Service.Type != "" {
+		return cr.Spec.Service.Type
+	}
+	return corev1.ServiceTypeNodePort
 }
 
 func (r *WordpressReconciler) serviceForWordpress(cr *v1.Wordpress) *corev1.Service {
@@ -254,7 +307,7 @@ func (r *WordpressReconciler) serviceForWordpress(cr *v1.Wordpress) *corev1.Serv
 	ser := &corev1.Service{
 
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "wordpress",
+			Name:      wordpressName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
@@ -268,9 +321,12 @@ func (r *WordpressReconciler) serviceForWordpress(cr *v1.Wordpress) *corev1.Serv
 					Name: "port",
 				},
 			},
-			Type: corev1.ServiceTypeNodePort,
+			Type: serviceType(cr),
 		},
 	}
+	if cr.Spec.Service != nil && len(cr.Spec.Service.Annotations) > 0 {
+		ser.Annotations = cr.Spec.Service.Annotations
+	}
 
 	controllerutil.SetControllerReference(cr, ser, r.Scheme)
 	return ser
@@ -285,7 +341,7 @@ func (r *WordpressReconciler) pvcForWordpress(cr *v1.Wordpress) *corev1.Persiste
 	pvc := &corev1.PersistentVolumeClaim{
 
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "wp-pv-claim",
+			Name:      wordpressPVCName(cr),
 			Namespace: cr.Namespace,
 			Labels:    labels,
 		},
@@ -295,15 +351,10 @@ func (r *WordpressReconciler) pvcForWordpress(cr *v1.Wordpress) *corev1.Persiste
 			AccessModes: []corev1.PersistentVolumeAccessMode{
 				"ReadWriteOnce",
 			},
-
-			Resources: corev1.ResourceRequirements{
-				Requests: map[corev1.ResourceName]resource.Quantity{
-					corev1.ResourceStorage: resource.MustParse("10Gi"),
-				},
-			},
 		},
 	}
 
+	applyVolumeSpec(pvc, wordpressVolume(cr))
 	controllerutil.SetControllerReference(cr, pvc, r.Scheme)
 	return pvc
 
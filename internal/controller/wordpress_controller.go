@@ -112,12 +112,19 @@ import (
 	"github.com/go-logr/logr"
 	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 )
 
 // WordpressReconciler reconciles a Wordpress object
@@ -127,15 +134,30 @@ type WordpressReconciler struct {
 	Scheme *runtime.Scheme
 }
 
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpresses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpresses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressbackups,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=core,resources=services;persistentvolumeclaims;secrets;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=cronjobs;jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
+
 // Reconcile function where you manage the WordPress and MySQL resources
-func (r *WordpressReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+func (r *WordpressReconciler) Reconcile(ctx context.Context, request ctrl.Request) (result ctrl.Result, err error) {
 	_ = r.Log.WithValues("wordpress", request.NamespacedName)
 
 	r.Log.Info("Reconciling Wordpress")
 
 	// Fetch the Wordpress instance
 	wordpress := &v1.Wordpress{}
-	err := r.Client.Get(ctx, request.NamespacedName, wordpress)
+	err = r.Client.Get(ctx, request.NamespacedName, wordpress)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			return ctrl.Result{}, nil
@@ -143,6 +165,41 @@ func (r *WordpressReconciler) Reconcile(ctx context.Context, request ctrl.Reques
 		return ctrl.Result{}, err
 	}
 
+	// Carry out the deletion policy before the garbage collector gets to
+	// the volumes
+	if !wordpress.DeletionTimestamp.IsZero() {
+		return r.finalizeInstance(ctx, wordpress)
+	}
+	if controllerutil.AddFinalizer(wordpress, instanceFinalizer) {
+		if err := r.Client.Update(ctx, wordpress); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Write whatever was observed to the status subresource, however this
+	// reconcile ends
+	originalStatus := wordpress.Status.DeepCopy()
+	defer func() {
+		summariseStatus(wordpress, err)
+		if statusErr := r.updateStatus(ctx, wordpress, originalStatus); statusErr != nil && err == nil {
+			err = statusErr
+		}
+	}()
+
+	// Instances installed before names were derived from the CR name keep
+	// their original object names
+	if err := r.detectLegacyNames(ctx, wordpress); err != nil {
+		r.Log.Error(err, "Failed to detect legacy object names")
+		return ctrl.Result{}, err
+	}
+
+	// Refuse to go further with credentials the user did not intend
+	creds, err := r.databaseCredentials(wordpress)
+	if err != nil {
+		r.Log.Error(err, "Invalid database credentials")
+		return ctrl.Result{}, err
+	}
+
 	// Step 1: Ensure MySQL Secret exists
 	mysqlSecret, err := r.createMysqlPasswordSecret(wordpress)
 	if err != nil {
@@ -152,104 +209,359 @@ func (r *WordpressReconciler) Reconcile(ctx context.Context, request ctrl.Reques
 
 	// Ensure MySQL Secret exists
 	if result, err := r.ensureMysqlSecret(request, wordpress, mysqlSecret); result != nil || err != nil {
-		return *result, err
+		return resultOrEmpty(result), err
+	}
+
+	// Ensure the Secret of the WordPress database user exists, unless the
+	// user brings their own
+	if creds.generatesWordpressUser(wordpress) {
+		wordpressDBSecret, err := r.secretForWordpressDatabase(wordpress)
+		if err != nil {
+			r.Log.Error(err, "Failed to create WordPress database Secret object")
+			return ctrl.Result{}, err
+		}
+		if result, err := r.ensureMysqlSecret(request, wordpress, wordpressDBSecret); result != nil || err != nil {
+			return resultOrEmpty(result), err
+		}
 	}
 
 	// Step 2: Ensure MySQL resources (PVC, Deployment, Service)
 	if result, err := r.ensureMysqlResources(request, wordpress); result != nil || err != nil {
-		return *result, err
+		return resultOrEmpty(result), err
+	}
+
+	// Ensure the database user WordPress connects as
+	if result, err := r.ensureDatabaseUser(request, wordpress); result != nil || err != nil {
+		return resultOrEmpty(result), err
 	}
 
 	// Step 3: Ensure WordPress resources (PVC, Deployment, Service)
 	if result, err := r.ensureWordpressResources(request, wordpress); result != nil || err != nil {
-		return *result, err
+		return resultOrEmpty(result), err
 	}
 
-	// Step 4: Ensure Backup resources (PVC, CronJob)
+	// Step 4: Ensure Backup resources (PVC, scheduled WordpressBackups)
 	if result, err := r.ensureBackupResources(request, wordpress); result != nil || err != nil {
-		return *result, err
+		return resultOrEmpty(result), err
+	}
+
+	// Step 5: Rotate the generated passwords when due
+	if result, err := r.ensureCredentialRotation(request, wordpress); result != nil || err != nil {
+		return resultOrEmpty(result), err
 	}
 
-	return ctrl.Result{}, nil
+	return requeueForSchedules(wordpress, time.Now()), nil
 }
 
 func (r *WordpressReconciler) ensureMysqlResources(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
-	// Ensure MySQL PVC
-	if result, err := r.ensurePVC(request, wordpress, r.pvcForMysql(wordpress)); result != nil || err != nil {
+	// Installs from before the StatefulSet ran MySQL as a Deployment on a
+	// single volume. Stop that Deployment and import its data into the primary.
+	legacyPVC, err := r.legacyMysqlVolume(wordpress)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.removeLegacyMysqlDeployment(wordpress); err != nil {
+		return nil, err
+	}
+
+	// Ensure MySQL Services, the headless one for the StatefulSet and the
+	// read-only one over the replicas
+	if result, err := r.ensureService(request, wordpress, r.serviceForMysql(wordpress)); result != nil || err != nil {
 		return result, err
 	}
+	if result, err := r.ensureService(request, wordpress, r.serviceForMysqlRead(wordpress)); result != nil || err != nil {
+		return result, err
+	}
+
+	// The primary of a cloned instance starts out from the snapshot of the
+	// database, the replicas clone the primary as usual
+	if err := r.ensureClonedVolume(wordpress, r.pvcForMysqlPrimary(wordpress), v1.SnapshotVolumeDatabase); err != nil {
+		return nil, err
+	}
 
-	// Ensure MySQL Deployment
-	mysqlDeployment, err := r.deploymentForMysql(wordpress)
+	// Ensure MySQL StatefulSet
+	mysqlStatefulSet, err := r.statefulSetForMysql(wordpress, legacyPVC)
 	if err != nil {
 		return nil, err
 	}
-	if result, err := r.ensureDeployment(request, wordpress, mysqlDeployment); result != nil || err != nil {
+	// The pods of every tier run under a ServiceAccount of their own, and
+	// only the pods of the instance may connect to MySQL
+	if result, err := r.ensureServiceAccounts(request, wordpress); result != nil || err != nil {
 		return result, err
 	}
-
-	// Ensure MySQL Service
-	if result, err := r.ensureService(request, wordpress, r.serviceForMysql(wordpress)); result != nil || err != nil {
+	if result, err := r.ensureNetworkPolicies(request, wordpress); result != nil || err != nil {
+		return result, err
+	}
+	if result, err := r.ensureStatefulSet(request, wordpress, mysqlStatefulSet); result != nil || err != nil {
+		return result, err
+	}
+	if result, err := r.ensureDisruptionBudget(request, wordpress, "mysql", mysqlName(wordpress), *mysqlStatefulSet.Spec.Replicas); result != nil || err != nil {
 		return result, err
 	}
 
+	// The volumes the StatefulSet created follow the size of its template
+	mysqlVolumes, err := r.mysqlVolumes(context.TODO(), wordpress)
+	if err != nil {
+		return nil, err
+	}
+	for i := range mysqlVolumes {
+		if err := r.ensureVolumeSize(context.TODO(), wordpress, "mysql", &mysqlVolumes[i], mysqlVolume(wordpress)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The read-only Service selects the replicas by the role of their pod
+	if err := r.ensureMysqlRoles(context.TODO(), wordpress); err != nil {
+		return nil, err
+	}
+
 	// Check if MySQL is running
 	mysqlRunning := r.isMysqlUp(wordpress)
+	if mysqlRunning && legacyPVC != "" {
+		// The primary only starts once the import init container is done
+		if err := r.markLegacyMysqlVolumeImported(wordpress, legacyPVC); err != nil {
+			return nil, err
+		}
+	}
 	if !mysqlRunning {
+		setCondition(wordpress, v1.ConditionMysqlReady, metav1.ConditionFalse, "MysqlNotReady", "Waiting for MySQL to become ready")
 		delay := time.Second * 5
 		r.Log.Info(fmt.Sprintf("MySQL isn't running, waiting for %s", delay))
 		return &ctrl.Result{RequeueAfter: delay}, nil
 	}
+	setCondition(wordpress, v1.ConditionMysqlReady, metav1.ConditionTrue, "MysqlReady",
+		fmt.Sprintf("Primary is up, %d of %d MySQL pods ready", wordpress.Status.MysqlReadyReplicas, *mysqlStatefulSet.Spec.Replicas))
 
 	return nil, nil
 }
 
-func (r *WordpressReconciler) ensureWordpressResources(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
-	// Ensure WordPress PVC
-	if result, err := r.ensurePVC(request, wordpress, r.pvcForWordpress(wordpress)); result != nil || err != nil {
+// ensureDatabaseUser runs the Job creating the WordPress database user and
+// holds WordPress back until it is done, so that WordPress never connects
+// with credentials the database does not know yet
+func (r *WordpressReconciler) ensureDatabaseUser(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
+	job, err := r.jobForMysqlUserBootstrap(wordpress)
+	if err != nil {
+		return nil, err
+	}
+	if result, err := r.ensureJob(request, wordpress, job); result != nil || err != nil {
 		return result, err
 	}
 
+	found := &batchv1.Job{}
+	if err := r.Client.Get(context.TODO(), types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found); err != nil {
+		return nil, err
+	}
+	if found.Status.Succeeded > 0 {
+		setCondition(wordpress, v1.ConditionDatabaseUserReady, metav1.ConditionTrue, "UserReady", "The WordPress database user is set up")
+		return nil, nil
+	}
+
+	for _, cond := range found.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			setCondition(wordpress, v1.ConditionDatabaseUserReady, metav1.ConditionFalse, "BootstrapFailed",
+				fmt.Sprintf("Job %s failed: %s", found.Name, cond.Message))
+			// Start over with a new Job
+			r.Log.Info("Deleting failed Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+			if err := r.Client.Delete(context.TODO(), found, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+				return nil, err
+			}
+			return &ctrl.Result{RequeueAfter: time.Minute}, nil
+		}
+	}
+
+	setCondition(wordpress, v1.ConditionDatabaseUserReady, metav1.ConditionFalse, "BootstrapPending",
+		fmt.Sprintf("Waiting for Job %s to complete", found.Name))
+	return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+}
+
+func (r *WordpressReconciler) ensureWordpressResources(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
+	// Ensure WordPress PVC, from the snapshot of the content for a cloned
+	// instance. Stateless instances have none.
+	if hasContentVolume(wordpress) {
+		wordpressPVC := r.pvcForWordpress(wordpress)
+		if err := r.ensureClonedVolume(wordpress, wordpressPVC, v1.SnapshotVolumeContent); err != nil {
+			return nil, err
+		}
+		if result, err := r.ensurePVC(request, wordpress, wordpressPVC); result != nil || err != nil {
+			return result, err
+		}
+		if err := r.ensureClaimSize(context.TODO(), wordpress, "wordpress", wordpressPVC.Name, wordpressVolume(wordpress)); err != nil {
+			return nil, err
+		}
+	}
+
 	// Ensure WordPress Deployment
-	wordpressDeployment := r.deploymentForWordpress(wordpress)
+	wordpressDeployment, err := r.deploymentForWordpress(wordpress)
+	if err != nil {
+		r.Log.Error(err, "Failed to build WordPress Deployment")
+		return &ctrl.Result{}, err
+	}
+	replicas := *wordpressDeployment.Spec.Replicas
+	if isAutoscaled(wordpress) && !restoreInProgress(wordpress) {
+		if replicas, err = r.autoscaledReplicas(wordpress, wordpressDeployment); err != nil {
+			return nil, err
+		}
+	}
 	if result, err := r.ensureDeployment(request, wordpress, wordpressDeployment); result != nil || err != nil {
 		return result, err
 	}
 
+	if result, err := r.ensureDisruptionBudget(request, wordpress, "frontend", wordpressName(wordpress), wordpressReplicas(wordpress)); result != nil || err != nil {
+		return result, err
+	}
+
+	// Scale WordPress with a HorizontalPodAutoscaler, when asked to
+	if isAutoscaled(wordpress) {
+		if result, err := r.ensureHPA(request, wordpress, r.hpaForWordpress(wordpress)); result != nil || err != nil {
+			return result, err
+		}
+	} else if err := r.removeOwned(context.TODO(), wordpress, &autoscalingv2.HorizontalPodAutoscaler{}, "HorizontalPodAutoscaler", wordpressName(wordpress)); err != nil {
+		return nil, err
+	}
+
 	// Ensure WordPress Service
-	if result, err := r.ensureService(request, wordpress, r.serviceForWordpress(wordpress)); result != nil || err != nil {
+	wordpressService := r.serviceForWordpress(wordpress)
+	if result, err := r.ensureService(request, wordpress, wordpressService); result != nil || err != nil {
 		return result, err
 	}
+	wordpress.Status.URL = fmt.Sprintf("http://%s.%s.svc", wordpressService.Name, wordpressService.Namespace)
+
+	// Expose the site through an Ingress or a Gateway, when asked to
+	if wordpress.Spec.Ingress != nil {
+		if result, err := r.ensureIngress(request, wordpress, r.ingressForWordpress(wordpress)); result != nil || err != nil {
+			return result, err
+		}
+	}
+	if wordpress.Spec.Gateway != nil {
+		if err := r.ensureHTTPRoute(context.TODO(), wordpress); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.removeExposure(context.TODO(), wordpress); err != nil {
+		return nil, err
+	}
+	if url := publicURL(wordpress); url != "" {
+		wordpress.Status.URL = url
+	}
+
+	// Report how many WordPress pods are serving. Deployment status changes
+	// trigger a new reconcile, so there is no need to requeue here.
+	wordpress.Status.WordpressReadyReplicas = r.readyReplicas(wordpress.Namespace, wordpressDeployment.Name)
+	if restoreInProgress(wordpress) {
+		setCondition(wordpress, v1.ConditionWordpressReady, metav1.ConditionFalse, "RestoreInProgress",
+			fmt.Sprintf("Stopped for restore %s", wordpress.Annotations[v1.RestoreInProgressAnnotation]))
+	} else if wordpress.Status.WordpressReadyReplicas < replicas {
+		setCondition(wordpress, v1.ConditionWordpressReady, metav1.ConditionFalse, "WordpressNotReady",
+			fmt.Sprintf("%d of %d replicas ready", wordpress.Status.WordpressReadyReplicas, replicas))
+	} else {
+		setCondition(wordpress, v1.ConditionWordpressReady, metav1.ConditionTrue, "WordpressReady", "All replicas are ready")
+	}
 
 	return nil, nil
 }
 
 func (r *WordpressReconciler) ensureBackupResources(request ctrl.Request, wordpress *v1.Wordpress) (*ctrl.Result, error) {
-	// Ensure Backup PVC
-	if result, err := r.ensureBackupPVC(request, wordpress, r.pvcForBackup(wordpress)); result != nil || err != nil {
-		return result, err
+	// Ensure Backup PVC, unless backups go to object storage
+	if s3Destination(wordpress) == nil {
+		if result, err := r.ensureBackupPVC(request, wordpress, r.pvcForBackup(wordpress)); result != nil || err != nil {
+			return result, err
+		}
+		if err := r.ensureClaimSize(context.TODO(), wordpress, "backup", backupPVCName(wordpress), backupVolume(wordpress)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Backups used to be taken by a CronJob
+	if err := r.removeBackupCronJob(wordpress); err != nil {
+		return nil, err
 	}
 
-	// Ensure Backup CronJob
-	backupCronJob, err := r.cronJobForMysqlBackup(wordpress)
+	backups, err := r.backupsOf(wordpress)
 	if err != nil {
 		return nil, err
 	}
-	if result, err := r.ensureCronJob(request, wordpress, backupCronJob); result != nil || err != nil {
-		return result, err
+
+	if !backupEnabled(wordpress) {
+		setCondition(wordpress, v1.ConditionBackupScheduled, metav1.ConditionFalse, "BackupsDisabled",
+			"Scheduled backups are turned off")
+		observeBackups(wordpress, backups, nil)
+		return nil, nil
 	}
 
+	// Take the scheduled backups and prune the old ones
+	method, err := r.scheduledBackupMethod(wordpress)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	next, err := r.ensureScheduledBackup(wordpress, backups, method, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.pruneScheduledBackups(wordpress, backups, now); err != nil {
+		return nil, err
+	}
+	if method != configuredBackupMethod(wordpress) {
+		setCondition(wordpress, v1.ConditionBackupScheduled, metav1.ConditionTrue, "FallbackToDump",
+			fmt.Sprintf("Backups run on schedule %q as dumps, %s", backupSchedule(wordpress), volumeSnapshotsUnavailable))
+	} else {
+		setCondition(wordpress, v1.ConditionBackupScheduled, metav1.ConditionTrue, "Scheduled",
+			fmt.Sprintf("Backups run on schedule %q", backupSchedule(wordpress)))
+	}
+
+	observeBackups(wordpress, backups, &next)
 	return nil, nil
 }
 
+// requeueForSchedules returns the result waking the instance up for the next
+// scheduled backup or credential rotation, whichever comes first. The MySQL
+// volumes are not owned by the instance, so it also checks back on volumes
+// being expanded.
+func requeueForSchedules(cr *v1.Wordpress, now time.Time) ctrl.Result {
+	_, wait := credentialRotationDue(cr, now)
+	if volumesResizing(cr) && (wait == 0 || wait > volumeResizeCheckInterval) {
+		wait = volumeResizeCheckInterval
+	}
+	if backup := cr.Status.Backup; backup != nil && backup.NextScheduleTime != nil {
+		backupWait := backup.NextScheduleTime.Sub(now)
+		if backupWait <= 0 {
+			// Postponed behind a running backup, which also wakes the
+			// instance up when it finishes
+			backupWait = time.Minute
+		}
+		if wait == 0 || backupWait < wait {
+			wait = backupWait
+		}
+	}
+	return ctrl.Result{RequeueAfter: wait}
+}
+
+// resultOrEmpty dereferences a step result, treating nil as an empty result
+func resultOrEmpty(result *ctrl.Result) ctrl.Result {
+	if result == nil {
+		return ctrl.Result{}
+	}
+	return *result
+}
+
 func (r *WordpressReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&v1.Wordpress{}).
 		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
 		Owns(&corev1.PersistentVolumeClaim{}).
-		Owns(&batchv1.CronJob{}).              // Watches for CronJob resources
-		Owns(&corev1.Secret{}).                // Watches for Secret resources
+		Owns(&batchv1.Job{}).   // Watches for the database user Job
+		Owns(&corev1.Secret{}). // Watches for Secret resources
+		Owns(&corev1.ServiceAccount{}).
 		Owns(&corev1.PersistentVolumeClaim{}). // Watches for PVCs, including backup PVCs
+		// Watches for user managed credentials Secrets
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.wordpressesForSecret)).
+		// Watches for the backups of each instance
+		Watches(&v1.WordpressBackup{}, handler.EnqueueRequestsFromMapFunc(wordpressForBackup)).
 		Complete(r)
 }
@@ -21,8 +21,15 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -46,12 +53,15 @@ var _ = Describe("Wordpress Controller", func() {
 			By("creating the custom resource for the Kind Wordpress")
 			err := k8sClient.Get(ctx, typeNamespacedName, wordpress)
 			if err != nil && errors.IsNotFound(err) {
+				mysqlReplicas := int32(1)
 				resource := &wordpressv1alpha1.Wordpress{
 					ObjectMeta: metav1.ObjectMeta{
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: wordpressv1alpha1.WordpressSpec{
+						MysqlReplicas: &mysqlReplicas,
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -61,10 +71,24 @@ var _ = Describe("Wordpress Controller", func() {
 			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &wordpressv1alpha1.Wordpress{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if errors.IsNotFound(err) {
+				return
+			}
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance Wordpress")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+
+			// envtest runs no garbage collector, the deletion policy
+			// releases the Secrets for the next test to take over
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
@@ -80,5 +104,715 @@ var _ = Describe("Wordpress Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+
+		It("should report progress in the status", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+			Expect(resource.Status.Phase).To(Equal(wordpressv1alpha1.PhaseProvisioning))
+
+			// envtest runs no pods, so MySQL never becomes ready
+			cond := meta.FindStatusCondition(resource.Status.Conditions, wordpressv1alpha1.ConditionMysqlReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		})
+
+		It("should derive object names from the CR name", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-mysql",
+				Namespace: "default",
+			}, sts)).To(Succeed())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-mysql-root-password-secret",
+				Namespace: "default",
+			}, secret)).To(Succeed())
+		})
+
+		It("should restore a drifted StatefulSet", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Reconciling the created resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Scaling the MySQL StatefulSet behind the operator's back")
+			sts := &appsv1.StatefulSet{}
+			stsName := types.NamespacedName{Name: resourceName + "-mysql", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			drifted := int32(3)
+			sts.Spec.Replicas = &drifted
+			Expect(k8sClient.Update(ctx, sts)).To(Succeed())
+
+			By("Reconciling again")
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(1)))
+		})
+		It("should roll out entries removed from the pod template", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := reconcile.Request{NamespacedName: typeNamespacedName}
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+			wordpress.Spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: "registry"}}
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			sts := &appsv1.StatefulSet{}
+			stsName := types.NamespacedName{Name: resourceName + "-mysql", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.ImagePullSecrets).To(HaveLen(1))
+
+			By("Removing the pull secret from the spec and adding an env var behind the operator's back")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+			wordpress.Spec.ImagePullSecrets = nil
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+			env := append([]corev1.EnvVar{}, sts.Spec.Template.Spec.Containers[0].Env...)
+			sts.Spec.Template.Spec.Containers[0].Env = append(sts.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "DRIFTED", Value: "true"})
+			Expect(k8sClient.Update(ctx, sts)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.ImagePullSecrets).To(BeEmpty())
+			Expect(sts.Spec.Template.Spec.Containers[0].Env).To(Equal(env))
+		})
+
+		It("should roll a changed image out to MySQL", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := reconcile.Request{NamespacedName: typeNamespacedName}
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			sts := &appsv1.StatefulSet{}
+			stsName := types.NamespacedName{Name: resourceName + "-mysql", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].Image).To(Equal(wordpressv1alpha1.DefaultDatabaseImage))
+
+			By("Upgrading the database image")
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+			wordpress.Spec.DatabaseImage = "mysql:8.4"
+			wordpress.Spec.ImagePullPolicy = corev1.PullAlways
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].Image).To(Equal("mysql:8.4"))
+			for _, container := range sts.Spec.Template.Spec.Containers {
+				Expect(container.ImagePullPolicy).To(Equal(corev1.PullAlways))
+			}
+		})
+
+		It("should generate a database user for WordPress", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-wordpress-db-secret",
+				Namespace: "default",
+			}, secret)).To(Succeed())
+			Expect(secret.Data).To(HaveKey(wordpressDBUsernameKey))
+			Expect(secret.Data).To(HaveKey(wordpressDBPasswordKey))
+			Expect(string(secret.Data[wordpressDBUsernameKey])).NotTo(Equal("root"))
+		})
+
+		It("should retain the data of a deleted instance for the next one", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(ContainElement(instanceFinalizer))
+
+			By("Deleting the instance")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, typeNamespacedName, resource))).To(BeTrue())
+
+			secret := &corev1.Secret{}
+			secretName := types.NamespacedName{Name: resourceName + "-mysql-root-password-secret", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(metav1.GetControllerOf(secret)).To(BeNil())
+			Expect(secret.Annotations).To(HaveKeyWithValue(wordpressv1alpha1.RetainedFromAnnotation, resourceName))
+
+			By("Creating the instance again")
+			mysqlReplicas := int32(1)
+			resource = &wordpressv1alpha1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{Name: resourceName, Namespace: "default"},
+				Spec:       wordpressv1alpha1.WordpressSpec{MysqlReplicas: &mysqlReplicas},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(metav1.IsControlledBy(secret, resource)).To(BeTrue())
+			Expect(secret.Annotations).NotTo(HaveKey(wordpressv1alpha1.RetainedFromAnnotation))
+		})
+
+		It("should wait for a final backup under the Snapshot deletion policy", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.DeletionPolicy = wordpressv1alpha1.DeletionPolicySnapshot
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Deleting the instance")
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			// The backup controller does not run, so the backup never finishes
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.Phase).To(Equal(wordpressv1alpha1.PhaseDeleting))
+			cond := meta.FindStatusCondition(resource.Status.Conditions, wordpressv1alpha1.ConditionReady)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Reason).To(Equal("FinalBackupPending"))
+
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: finalBackupName(resource), Namespace: "default"}, backup)).To(Succeed())
+			Expect(backup.Spec.WordpressRef.Name).To(Equal(resourceName))
+			Expect(k8sClient.Delete(ctx, backup)).To(Succeed())
+
+			By("Giving up on the final backup")
+			resource.Spec.DeletionPolicy = wordpressv1alpha1.DeletionPolicyDelete
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+		})
+
+		It("should expand the MySQL volumes when the size grows", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Creating a bound volume the way the StatefulSet would")
+			allowExpansion := true
+			class := &storagev1.StorageClass{
+				ObjectMeta:           metav1.ObjectMeta{Name: "expandable"},
+				Provisioner:          "example.com/csi",
+				AllowVolumeExpansion: &allowExpansion,
+			}
+			Expect(k8sClient.Create(ctx, class)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, class)
+
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "mysql-persistent-storage-" + resourceName + "-mysql-0",
+					Namespace: "default",
+					Labels:    map[string]string{"app": resourceName},
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					StorageClassName: &class.Name,
+					VolumeName:       "pv-" + resourceName,
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pvc)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, pvc)
+			pvc.Status.Phase = corev1.ClaimBound
+			pvc.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}
+			Expect(k8sClient.Status().Update(ctx, pvc)).To(Succeed())
+
+			By("Growing the MySQL volume")
+			instance := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, instance)).To(Succeed())
+			size := resource.MustParse("20Gi")
+			instance.Spec.Storage = &wordpressv1alpha1.StorageSpec{Mysql: wordpressv1alpha1.VolumeSpec{Size: &size}}
+			Expect(k8sClient.Update(ctx, instance)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: "default"}, pvc)).To(Succeed())
+			requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			Expect(requested.String()).To(Equal("20Gi"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, instance)).To(Succeed())
+			Expect(instance.Status.Volumes).To(ContainElement(And(
+				HaveField("ClaimName", pvc.Name),
+				HaveField("Volume", "mysql"),
+				HaveField("Expansion", wordpressv1alpha1.VolumeResizing),
+			)))
+		})
+
+		It("should run stateless instances without a content volume", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Content = &wordpressv1alpha1.ContentSpec{
+				Mode: wordpressv1alpha1.ContentStateless,
+				Uploads: &wordpressv1alpha1.UploadsSpec{
+					Bucket:               "media",
+					CredentialsSecretRef: corev1.LocalObjectReference{Name: "media-credentials"},
+				},
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			dep, err := controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Volumes[0].EmptyDir).NotTo(BeNil())
+			Expect(dep.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim).To(BeNil())
+			Expect(dep.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				corev1.EnvVar{Name: "S3_UPLOADS_BUCKET", Value: "media/default/" + resourceName + "/uploads"}))
+			Expect(scheduledBackupsIncludeContent(resource)).To(BeFalse())
+		})
+
+		It("should expose the site on its public URL", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Ingress = &wordpressv1alpha1.IngressSpec{
+				Hosts: []string{"blog.example.com", "www.blog.example.com"},
+				TLS: &wordpressv1alpha1.IngressTLS{
+					Issuer: &wordpressv1alpha1.IssuerReference{Name: "letsencrypt", Kind: "ClusterIssuer"},
+				},
+			}
+
+			ing := controllerReconciler.ingressForWordpress(resource)
+			Expect(ing.Spec.Rules).To(HaveLen(2))
+			Expect(ing.Annotations).To(HaveKeyWithValue("cert-manager.io/cluster-issuer", "letsencrypt"))
+			Expect(ing.Spec.TLS).To(HaveLen(1))
+			Expect(ing.Spec.TLS[0].SecretName).To(Equal(resourceName + "-tls"))
+
+			env := wordpressConfigEnv(resource)
+			Expect(env).To(ContainElement(corev1.EnvVar{Name: "WP_HOME", Value: "https://blog.example.com"}))
+			Expect(env).To(ContainElement(HaveField("Name", "WORDPRESS_CONFIG_EXTRA")))
+		})
+
+		It("should probe the database and take probe overrides from the spec", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Probes = &wordpressv1alpha1.ProbesSpec{
+				Wordpress: &wordpressv1alpha1.ContainerProbes{
+					Readiness: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(80)},
+						},
+					},
+				},
+			}
+
+			sts, err := controllerReconciler.statefulSetForMysql(resource, "")
+			Expect(err).NotTo(HaveOccurred())
+			mysql := sts.Spec.Template.Spec.Containers[0]
+			Expect(mysql.ReadinessProbe.Exec.Command).To(ContainElement(mysqlReadinessScript))
+			Expect(mysql.LivenessProbe.Exec.Command).To(ContainElement(mysqlPingScript))
+			Expect(mysql.StartupProbe).NotTo(BeNil())
+
+			dep, err := controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			wordpress := dep.Spec.Template.Spec.Containers[0]
+			Expect(wordpress.ReadinessProbe.HTTPGet.Path).To(Equal("/healthz"))
+			Expect(wordpress.LivenessProbe.TCPSocket.Port.IntValue()).To(Equal(80))
+		})
+
+		It("should spread WordPress across nodes and apply the pod overrides", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+
+			dep, err := controllerReconciler.deploymentForWordpress(wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			podSpec := dep.Spec.Template.Spec
+			Expect(podSpec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution).To(HaveLen(1))
+			Expect(podSpec.Containers[0].Resources.Requests).To(HaveKey(corev1.ResourceMemory))
+
+			wordpress.Spec.Pods = &wordpressv1alpha1.PodsSpec{
+				Mysql: &wordpressv1alpha1.PodTemplateOverrides{
+					NodeSelector:      map[string]string{"disktype": "ssd"},
+					PriorityClassName: "database",
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+					},
+				},
+			}
+			sts, err := controllerReconciler.statefulSetForMysql(wordpress, "")
+			Expect(err).NotTo(HaveOccurred())
+			podSpec = sts.Spec.Template.Spec
+			Expect(podSpec.NodeSelector).To(HaveKeyWithValue("disktype", "ssd"))
+			Expect(podSpec.PriorityClassName).To(Equal("database"))
+			Expect(podSpec.Containers[0].Resources.Requests.Memory().String()).To(Equal("2Gi"))
+			Expect(podSpec.Containers[0].Resources.Requests).NotTo(HaveKey(corev1.ResourceCPU))
+			// The replication sidecar keeps its default requests
+			Expect(podSpec.Containers[1].Resources.Requests).To(HaveKey(corev1.ResourceMemory))
+		})
+
+		It("should request resources for the sidecars", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+			wordpress.Spec.Image = &wordpressv1alpha1.WordpressImage{Variant: wordpressv1alpha1.VariantFPM}
+
+			dep, err := controllerReconciler.deploymentForWordpress(wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers).To(HaveLen(2))
+			Expect(dep.Spec.Template.Spec.Containers[1].Resources).To(Equal(sidecarResources))
+
+			wordpress.Spec.Pods = &wordpressv1alpha1.PodsSpec{
+				Wordpress: &wordpressv1alpha1.PodTemplateOverrides{
+					SidecarResources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")},
+					},
+				},
+			}
+			dep, err = controllerReconciler.deploymentForWordpress(wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.Containers[1].Resources.Requests.Memory().String()).To(Equal("256Mi"))
+			// The main container keeps its default requests
+			Expect(dep.Spec.Template.Spec.Containers[0].Resources).To(Equal(wordpressResources))
+		})
+
+		It("should scale WordPress with a HorizontalPodAutoscaler", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			minReplicas := int32(2)
+			resource.Spec.Autoscaling = &wordpressv1alpha1.AutoscalingSpec{MinReplicas: &minReplicas, MaxReplicas: 10}
+
+			hpa := controllerReconciler.hpaForWordpress(resource)
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(wordpressName(resource)))
+			Expect(*hpa.Spec.MinReplicas).To(Equal(int32(2)))
+			Expect(hpa.Spec.Metrics).To(HaveLen(1))
+			Expect(*hpa.Spec.Metrics[0].Resource.Target.AverageUtilization).To(Equal(defaultTargetCPUUtilization))
+
+			// A new Deployment starts at the minimum, after that the replicas
+			// are left to the HorizontalPodAutoscaler
+			dep, err := controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			replicas, err := controllerReconciler.autoscaledReplicas(resource, dep)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(Equal(int32(2)))
+			Expect(*dep.Spec.Replicas).To(Equal(int32(2)))
+			Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+			defer func() { Expect(k8sClient.Delete(ctx, dep)).To(Succeed()) }()
+
+			dep, err = controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			replicas, err = controllerReconciler.autoscaledReplicas(resource, dep)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(replicas).To(Equal(int32(2)))
+			Expect(dep.Spec.Replicas).To(BeNil())
+		})
+
+		It("should only budget for disruptions of tiers with several pods", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(controllerReconciler.pdbForTier(resource, "mysql", mysqlName(resource), 1)).To(BeNil())
+
+			pdb := controllerReconciler.pdbForTier(resource, "frontend", wordpressName(resource), 3)
+			Expect(pdb.Spec.MaxUnavailable.IntValue()).To(Equal(1))
+			Expect(pdb.Spec.Selector.MatchLabels).To(HaveKeyWithValue("tier", "frontend"))
+
+			minAvailable := intstr.FromInt(1)
+			resource.Spec.DisruptionBudgets = &wordpressv1alpha1.DisruptionBudgetsSpec{
+				Mysql: &wordpressv1alpha1.DisruptionBudget{MinAvailable: &minAvailable},
+			}
+			pdb = controllerReconciler.pdbForTier(resource, "mysql", mysqlName(resource), 1)
+			Expect(pdb.Spec.MinAvailable.IntValue()).To(Equal(1))
+			Expect(pdb.Spec.MaxUnavailable).To(BeNil())
+		})
+
+		It("should run unprivileged pods and isolate the database", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+
+			dep, err := controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			podSpec := dep.Spec.Template.Spec
+			Expect(*podSpec.SecurityContext.RunAsNonRoot).To(BeTrue())
+			Expect(podSpec.SecurityContext.SeccompProfile.Type).To(Equal(corev1.SeccompProfileTypeRuntimeDefault))
+			for _, container := range podSpec.Containers {
+				Expect(*container.SecurityContext.AllowPrivilegeEscalation).To(BeFalse())
+				Expect(*container.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+				Expect(container.SecurityContext.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+			}
+			Expect(dep.Spec.Template.Labels).To(HaveKeyWithValue(databaseClientLabel, resourceName))
+			Expect(dep.Spec.Selector.MatchLabels).NotTo(HaveKey(databaseClientLabel))
+
+			np := controllerReconciler.networkPolicyForMysql(resource)
+			Expect(np.Spec.PodSelector.MatchLabels).To(HaveKeyWithValue("tier", "mysql"))
+			Expect(np.Spec.Ingress[0].From[0].PodSelector.MatchLabels).To(HaveKeyWithValue(databaseClientLabel, resourceName))
+		})
+
+		It("should spread reads over the MySQL replicas only", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+
+			// envtest runs no StatefulSet controller, create its pods by hand
+			var pods []*corev1.Pod
+			for _, ordinal := range []string{"0", "1"} {
+				pod := &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      mysqlName(wordpress) + "-" + ordinal,
+						Namespace: wordpress.Namespace,
+						Labels:    map[string]string{"app": wordpress.Name, "tier": "mysql"},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "mysql", Image: "mysql"}},
+					},
+				}
+				Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+				pods = append(pods, pod)
+			}
+			defer func() {
+				for _, pod := range pods {
+					Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+				}
+			}()
+
+			Expect(controllerReconciler.ensureMysqlRoles(ctx, wordpress)).To(Succeed())
+			for i, role := range []string{"primary", "replica"} {
+				Expect(k8sClient.Get(ctx, types.NamespacedName{Name: pods[i].Name, Namespace: pods[i].Namespace}, pods[i])).To(Succeed())
+				Expect(pods[i].Labels).To(HaveKeyWithValue(mysqlRoleLabel, role))
+			}
+
+			ser := controllerReconciler.serviceForMysqlRead(wordpress)
+			Expect(ser.Spec.Selector).To(HaveKeyWithValue(mysqlRoleLabel, "replica"))
+		})
+
+		It("should run each tier under a ServiceAccount of its own", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ServiceAccounts = &wordpressv1alpha1.ServiceAccountsSpec{
+				Jobs: &wordpressv1alpha1.ServiceAccountSpec{
+					Annotations: map[string]string{"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/backups"},
+				},
+			}
+
+			sa := controllerReconciler.serviceAccountFor(resource, "jobs")
+			Expect(sa.Name).To(Equal(resourceName + "-jobs"))
+			Expect(*sa.AutomountServiceAccountToken).To(BeFalse())
+			Expect(sa.Annotations).To(HaveKey("eks.amazonaws.com/role-arn"))
+
+			dep, err := controllerReconciler.deploymentForWordpress(resource)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dep.Spec.Template.Spec.ServiceAccountName).To(Equal(resourceName + "-wordpress"))
+			Expect(*dep.Spec.Template.Spec.AutomountServiceAccountToken).To(BeFalse())
+		})
+
+		It("should discard the old passwords once the workloads rolled onto the new ones", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			request := reconcile.Request{NamespacedName: typeNamespacedName}
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, wordpress)).To(Succeed())
+
+			// A rotation promoted its passwords
+			secret := &corev1.Secret{}
+			secretName := types.NamespacedName{Name: mysqlSecretName(wordpress), Namespace: wordpress.Namespace}
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			if secret.Annotations == nil {
+				secret.Annotations = map[string]string{}
+			}
+			secret.Annotations[credentialDiscardAnnotation] = "1"
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+				delete(secret.Annotations, credentialDiscardAnnotation)
+				Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			}()
+			rotatedAt := metav1.Unix(1, 0)
+			wordpress.Status.LastCredentialRotation = &rotatedAt
+			discardJob := types.NamespacedName{Name: mysqlName(wordpress) + "-discard-1", Namespace: wordpress.Namespace}
+
+			// The StatefulSet still runs the pod template from before the rotation
+			_, err = controllerReconciler.ensureCredentialRotation(request, wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, discardJob, &batchv1.Job{}))).To(BeTrue())
+
+			// envtest runs no StatefulSet controller, roll MySQL by hand
+			sts := &appsv1.StatefulSet{}
+			stsName := types.NamespacedName{Name: mysqlName(wordpress), Namespace: wordpress.Namespace}
+			Expect(k8sClient.Get(ctx, stsName, sts)).To(Succeed())
+			sts.Spec.Template.Annotations = credentialsRotatedAt(wordpress)
+			Expect(k8sClient.Update(ctx, sts)).To(Succeed())
+			sts.Status.ObservedGeneration = sts.Generation
+			sts.Status.CurrentRevision = "rotated"
+			sts.Status.UpdateRevision = "rotated"
+			Expect(k8sClient.Status().Update(ctx, sts)).To(Succeed())
+
+			_, err = controllerReconciler.ensureCredentialRotation(request, wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, discardJob, job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Command).To(ContainElement(mysqlDiscardOldPasswordsScript))
+			Expect(k8sClient.Delete(ctx, job)).To(Succeed())
+		})
+
+		It("should take the credentials from the referenced Secret", func() {
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("Referencing a Secret that does not exist yet")
+			resource := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.CredentialsSecretRef = &wordpressv1alpha1.CredentialsSecretReference{Name: "test-credentials"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).To(HaveOccurred())
+
+			By("Creating the Secret")
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-credentials", Namespace: "default"},
+				Data: map[string][]byte{
+					wordpressv1alpha1.DefaultRootPasswordKey: []byte("root"),
+					wordpressv1alpha1.DefaultUsernameKey:     []byte("wordpress"),
+					wordpressv1alpha1.DefaultPasswordKey:     []byte("secret"),
+				},
+			}
+			Expect(k8sClient.Create(ctx, credentials)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, credentials)
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: resourceName + "-mysql", Namespace: "default"}, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				secretEnv("MYSQL_ROOT_PASSWORD", secretKey("test-credentials", wordpressv1alpha1.DefaultRootPasswordKey))))
+		})
 	})
 })
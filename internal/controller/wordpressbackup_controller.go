@@ -0,0 +1,610 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// backupFinalizer removes the backup set of a WordpressBackup before the
+// WordpressBackup goes away
+const backupFinalizer = "wordpress.gopkg.blogpost.com/backup-set"
+
+// WordpressBackupReconciler reconciles a WordpressBackup object
+type WordpressBackupReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressbackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressbackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=snapshot.storage.k8s.io,resources=volumesnapshots,verbs=get;list;watch;create;delete
+
+// Reconcile runs the Job taking a backup and records its outcome. Once a
+// backup has succeeded or failed it is left alone until it is deleted.
+func (r *WordpressBackupReconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.Result, error) {
+	_ = r.Log.WithValues("wordpressbackup", request.NamespacedName)
+
+	backup := &v1.WordpressBackup{}
+	if err := r.Client.Get(ctx, request.NamespacedName, backup); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if !backup.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if result, err := r.removeBackupSet(ctx, backup); result != nil || err != nil {
+			return resultOrEmpty(result), err
+		}
+		controllerutil.RemoveFinalizer(backup, backupFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, backup)
+	}
+
+	if controllerutil.AddFinalizer(backup, backupFinalizer) {
+		return ctrl.Result{}, r.Client.Update(ctx, backup)
+	}
+	if backupFinished(backup) {
+		return ctrl.Result{}, nil
+	}
+
+	wordpress := &v1.Wordpress{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: backup.Spec.WordpressRef.Name, Namespace: backup.Namespace}, wordpress)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed,
+				fmt.Sprintf("Wordpress %q not found", backup.Spec.WordpressRef.Name))
+		}
+		return ctrl.Result{}, err
+	}
+
+	if backup.Status.Phase == "" {
+		method := backup.Spec.Method
+		if method == "" {
+			// Follow the scheduled backups, falling back to dumps like they do
+			method, err = r.wordpressReconciler().scheduledBackupMethod(wordpress)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		backup.Status.Method = method
+		recordBackupContent(wordpress, backup)
+		if isSnapshotBackup(backup) {
+			recordVolumeSnapshots(backup)
+		} else {
+			recordBackupDestination(wordpress, backup)
+		}
+		backup.Status.Phase = v1.BackupPending
+		if err := r.Client.Status().Update(ctx, backup); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if isSnapshotBackup(backup) {
+		return r.reconcileSnapshotBackup(ctx, wordpress, backup)
+	}
+
+	job, err := r.wordpressReconciler().jobForBackup(wordpress, backup)
+	if err != nil {
+		r.Log.Error(err, "Failed to build backup Job")
+		return ctrl.Result{}, err
+	}
+	found := &batchv1.Job{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		// A backup taken now would capture a half restored site
+		if restoreInProgress(wordpress) {
+			backup.Status.Message = fmt.Sprintf("Waiting for restore %s to finish", wordpress.Annotations[v1.RestoreInProgressAnnotation])
+			return ctrl.Result{RequeueAfter: time.Second * 30}, r.Client.Status().Update(ctx, backup)
+		}
+		r.Log.Info("Creating backup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err := r.Client.Create(ctx, job); err != nil {
+			r.Log.Error(err, "Failed to create backup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		r.Log.Error(err, "Failed to get backup Job")
+		return ctrl.Result{}, err
+	}
+	if !metav1.IsControlledBy(found, backup) {
+		return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed,
+			fmt.Sprintf("Job %s exists and is not managed by this backup", found.Name))
+	}
+
+	if found.Status.Succeeded > 0 {
+		report, err := r.backupReport(ctx, found)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if report != nil {
+			backup.Status.Size = report.Size
+			backup.Status.Checksum = report.Checksum
+			backup.Status.Files = report.Files
+		}
+		r.Log.Info("Backup succeeded", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+		return ctrl.Result{}, r.finishBackup(ctx, backup, found, v1.BackupSucceeded,
+			fmt.Sprintf("Backup set written to %s", backup.Status.Location))
+	}
+	if message, failed := jobFailure(found); failed {
+		r.Log.Info("Backup failed", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+		return ctrl.Result{}, r.finishBackup(ctx, backup, found, v1.BackupFailed,
+			fmt.Sprintf("Job %s failed: %s", found.Name, message))
+	}
+
+	if found.Status.StartTime != nil && backup.Status.Phase == v1.BackupPending {
+		backup.Status.Phase = v1.BackupRunning
+		backup.Status.StartTime = found.Status.StartTime
+		backup.Status.Message = fmt.Sprintf("Job %s is running", found.Name)
+		return ctrl.Result{}, r.Client.Status().Update(ctx, backup)
+	}
+	return ctrl.Result{}, nil
+}
+
+// reconcileSnapshotBackup takes the VolumeSnapshots of a backup. The tables
+// are locked by a Job until every snapshot is cut, which usually takes
+// seconds, then the backup waits for the snapshots to become ready to use.
+func (r *WordpressBackupReconciler) reconcileSnapshotBackup(ctx context.Context, cr *v1.Wordpress, backup *v1.WordpressBackup) (ctrl.Result, error) {
+	available, err := volumeSnapshotsAvailable(r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !available {
+		r.Log.Info("Cannot take VolumeSnapshots", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+		return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed, "Cannot take VolumeSnapshots, "+volumeSnapshotsUnavailable)
+	}
+
+	var lockJob *batchv1.Job
+	found := &batchv1.Job{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: tableLockJobName(backup), Namespace: backup.Namespace}, found)
+	if err == nil {
+		if !metav1.IsControlledBy(found, backup) {
+			return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed,
+				fmt.Sprintf("Job %s exists and is not managed by this backup", found.Name))
+		}
+		lockJob = found
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	snapshots := make([]*unstructured.Unstructured, len(backup.Status.VolumeSnapshots))
+	taken := true
+	for i, snap := range backup.Status.VolumeSnapshots {
+		if snapshots[i], err = getVolumeSnapshot(ctx, r.Client, backup.Namespace, snap.Name); err != nil {
+			return ctrl.Result{}, err
+		}
+		taken = taken && snapshots[i] != nil
+	}
+	if !taken {
+		return r.takeVolumeSnapshots(ctx, cr, backup, lockJob, snapshots)
+	}
+
+	cut, ready := true, true
+	var size resource.Quantity
+	for i, vs := range snapshots {
+		state := volumeSnapshotStateOf(vs)
+		if state.err != "" {
+			if err := r.unlockTables(ctx, lockJob); err != nil {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed,
+				fmt.Sprintf("VolumeSnapshot %s failed: %s", vs.GetName(), state.err))
+		}
+		cut = cut && state.cut
+		ready = ready && state.ready
+		backup.Status.VolumeSnapshots[i].ReadyToUse = state.ready
+		if state.restoreSize != nil {
+			size.Add(*state.restoreSize)
+		}
+	}
+
+	// The tables stay locked until every snapshot is cut
+	if !cut {
+		if tableLockEnded(lockJob) {
+			return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupFailed,
+				"The tables were unlocked before the VolumeSnapshots were cut")
+		}
+		return ctrl.Result{RequeueAfter: time.Second * 2}, nil
+	}
+	if err := r.unlockTables(ctx, lockJob); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !ready {
+		backup.Status.Message = "Waiting for the VolumeSnapshots to be ready to use"
+		return ctrl.Result{RequeueAfter: time.Second * 10}, r.Client.Status().Update(ctx, backup)
+	}
+	backup.Status.Size = size.Value()
+	r.Log.Info("Backup succeeded", "WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name)
+	return ctrl.Result{}, r.finishBackup(ctx, backup, nil, v1.BackupSucceeded,
+		fmt.Sprintf("VolumeSnapshots %s are ready to use", backup.Status.Location))
+}
+
+// takeVolumeSnapshots locks the tables and creates the VolumeSnapshots of a
+// backup that do not exist yet
+func (r *WordpressBackupReconciler) takeVolumeSnapshots(ctx context.Context, cr *v1.Wordpress, backup *v1.WordpressBackup,
+	lockJob *batchv1.Job, snapshots []*unstructured.Unstructured) (ctrl.Result, error) {
+	if lockJob == nil {
+		// A snapshot taken now would capture a half restored site
+		if restoreInProgress(cr) {
+			backup.Status.Message = fmt.Sprintf("Waiting for restore %s to finish", cr.Annotations[v1.RestoreInProgressAnnotation])
+			return ctrl.Result{RequeueAfter: time.Second * 30}, r.Client.Status().Update(ctx, backup)
+		}
+		job, err := r.wordpressReconciler().jobForTableLock(cr, backup)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Creating table lock Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err := r.Client.Create(ctx, job); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: time.Second * 2}, nil
+	}
+	if tableLockEnded(lockJob) {
+		message := fmt.Sprintf("Job %s ended before the VolumeSnapshots were taken", lockJob.Name)
+		if failure, failed := jobFailure(lockJob); failed {
+			message = fmt.Sprintf("Job %s failed: %s", lockJob.Name, failure)
+		}
+		return ctrl.Result{}, r.finishBackup(ctx, backup, lockJob, v1.BackupFailed, message)
+	}
+
+	if backup.Status.Phase == v1.BackupPending {
+		now := metav1.Now()
+		backup.Status.Phase = v1.BackupRunning
+		backup.Status.StartTime = &now
+	}
+	locked, err := r.tablesLocked(ctx, lockJob)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !locked {
+		backup.Status.Message = fmt.Sprintf("Waiting for Job %s to lock the tables", lockJob.Name)
+		return ctrl.Result{RequeueAfter: time.Second * 2}, r.Client.Status().Update(ctx, backup)
+	}
+
+	// Clones of the backup need the root password the snapshot knows
+	wr := r.wordpressReconciler()
+	secret, err := wr.secretForSnapshotCredentials(cr, backup)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Client.Create(ctx, secret); err != nil && !errors.IsAlreadyExists(err) {
+		return ctrl.Result{}, err
+	}
+
+	for i, snap := range backup.Status.VolumeSnapshots {
+		if snapshots[i] != nil {
+			continue
+		}
+		vs, err := wr.volumeSnapshotFor(cr, backup, snap.Volume)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Log.Info("Creating VolumeSnapshot", "VolumeSnapshot.Namespace", vs.GetNamespace(), "VolumeSnapshot.Name", vs.GetName())
+		if err := r.Client.Create(ctx, vs); err != nil && !errors.IsAlreadyExists(err) {
+			return ctrl.Result{}, err
+		}
+	}
+	backup.Status.Message = "Taking the VolumeSnapshots with the tables locked"
+	return ctrl.Result{RequeueAfter: time.Second * 2}, r.Client.Status().Update(ctx, backup)
+}
+
+// tablesLocked reports whether the pod of a table lock Job holds the lock
+func (r *WordpressBackupReconciler) tablesLocked(ctx context.Context, job *batchv1.Job) (bool, error) {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return false, err
+	}
+	for _, pod := range pods.Items {
+		for _, cond := range pod.Status.Conditions {
+			if cond.Type == corev1.PodReady && cond.Status == corev1.ConditionTrue {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// tableLockEnded reports whether a table lock Job is gone or has finished,
+// which released the lock
+func tableLockEnded(job *batchv1.Job) bool {
+	if job == nil || job.Status.Succeeded > 0 {
+		return true
+	}
+	_, failed := jobFailure(job)
+	return failed
+}
+
+// unlockTables deletes a table lock Job. Its pod goes away, and with it the
+// session holding the lock.
+func (r *WordpressBackupReconciler) unlockTables(ctx context.Context, job *batchv1.Job) error {
+	if job == nil || !job.DeletionTimestamp.IsZero() {
+		return nil
+	}
+	r.Log.Info("Unlocking the tables", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+	err := r.Client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+	return client.IgnoreNotFound(err)
+}
+
+// removeVolumeSnapshots deletes the VolumeSnapshots of a deleted backup
+func (r *WordpressBackupReconciler) removeVolumeSnapshots(ctx context.Context, backup *v1.WordpressBackup) error {
+	available, err := volumeSnapshotsAvailable(r.Client)
+	if err != nil || !available {
+		return err
+	}
+	for _, snap := range backup.Status.VolumeSnapshots {
+		vs := &unstructured.Unstructured{}
+		vs.SetGroupVersionKind(volumeSnapshotGVK)
+		vs.SetName(snap.Name)
+		vs.SetNamespace(backup.Namespace)
+		r.Log.Info("Deleting VolumeSnapshot", "VolumeSnapshot.Namespace", backup.Namespace, "VolumeSnapshot.Name", snap.Name)
+		if err := r.Client.Delete(ctx, vs); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// wordpressReconciler shares the object builders of the Wordpress controller
+func (r *WordpressBackupReconciler) wordpressReconciler() *WordpressReconciler {
+	return &WordpressReconciler{
+		Client: r.Client,
+		Log:    r.Log,
+		Scheme: r.Scheme,
+	}
+}
+
+// recordBackupDestination copies the destination of the instance into the
+// status of a backup. The backup set can be found, and removed, from there
+// after the instance changed destination or was deleted.
+func recordBackupDestination(cr *v1.Wordpress, backup *v1.WordpressBackup) {
+	if s3 := s3Destination(cr); s3 != nil {
+		backup.Status.S3 = s3.DeepCopy()
+		backup.Status.S3.Prefix = s3Prefix(cr, s3)
+	} else {
+		backup.Status.ClaimName = backupPVCName(cr)
+	}
+	backup.Status.Location = backupSetLocation(backup.Status.ClaimName, backup.Status.S3, backup.Name)
+}
+
+// finishBackup records the end of a backup. job is nil when the backup failed
+// before its Job ran.
+func (r *WordpressBackupReconciler) finishBackup(ctx context.Context, backup *v1.WordpressBackup, job *batchv1.Job, phase v1.BackupPhase, message string) error {
+	now := metav1.Now()
+	backup.Status.Phase = phase
+	backup.Status.Message = message
+	backup.Status.CompletionTime = &now
+	if job != nil {
+		if backup.Status.StartTime == nil {
+			backup.Status.StartTime = job.Status.StartTime
+		}
+		if job.Status.CompletionTime != nil {
+			backup.Status.CompletionTime = job.Status.CompletionTime
+		}
+	}
+	return r.Client.Status().Update(ctx, backup)
+}
+
+// backupSetReport is what the finalize container of a backup Job reports in
+// its termination message
+type backupSetReport struct {
+	Size     int64           `json:"size"`
+	Checksum string          `json:"checksum"`
+	Files    []v1.BackupFile `json:"files"`
+}
+
+// backupReport reads the report of a succeeded backup Job. It is nil when the
+// pod is gone already.
+func (r *WordpressBackupReconciler) backupReport(ctx context.Context, job *batchv1.Job) (*backupSetReport, error) {
+	pods := &corev1.PodList{}
+	if err := r.Client.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if status.Name != "finalize" || terminated == nil || terminated.ExitCode != 0 {
+				continue
+			}
+			report := &backupSetReport{}
+			if err := json.Unmarshal([]byte(terminated.Message), report); err != nil {
+				r.Log.Error(err, "Failed to read the backup report", "Pod.Namespace", pod.Namespace, "Pod.Name", pod.Name)
+				return nil, nil
+			}
+			return report, nil
+		}
+	}
+	r.Log.Info("No backup report found", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+	return nil, nil
+}
+
+// removeBackupSet runs a Job removing the backup set of a deleted backup. A
+// backup set that cannot be reached anymore is left behind rather than
+// holding up the deletion: backup volumes are deleted along with their
+// instance, and S3 needs the credentials Secret. VolumeSnapshots are deleted
+// right away.
+func (r *WordpressBackupReconciler) removeBackupSet(ctx context.Context, backup *v1.WordpressBackup) (*ctrl.Result, error) {
+	if isSnapshotBackup(backup) {
+		return nil, r.removeVolumeSnapshots(ctx, backup)
+	}
+	if backup.Status.Location == "" {
+		// Nothing was written
+		return nil, nil
+	}
+
+	// Stop a backup that is still running, it would write behind the cleanup
+	backupJob := &batchv1.Job{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: backupJobName(backup), Namespace: backup.Namespace}, backupJob)
+	if err == nil && backupJob.Status.Succeeded == 0 {
+		if _, failed := jobFailure(backupJob); !failed {
+			// Wait for its pods to be gone
+			if backupJob.DeletionTimestamp.IsZero() {
+				r.Log.Info("Stopping backup Job", "Job.Namespace", backupJob.Namespace, "Job.Name", backupJob.Name)
+				if err := r.Client.Delete(ctx, backupJob, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil && !errors.IsNotFound(err) {
+					return nil, err
+				}
+			}
+			return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+		}
+	} else if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var wordpress *v1.Wordpress
+	found := &v1.Wordpress{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: backup.Spec.WordpressRef.Name, Namespace: backup.Namespace}, found)
+	if err == nil {
+		wordpress = found
+	} else if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var reachable client.Object
+	var key types.NamespacedName
+	if s3 := backup.Status.S3; s3 != nil {
+		reachable, key = &corev1.Secret{}, types.NamespacedName{Name: s3.CredentialsSecretRef.Name, Namespace: backup.Namespace}
+	} else if wordpress != nil {
+		reachable, key = &corev1.PersistentVolumeClaim{}, types.NamespacedName{Name: backup.Status.ClaimName, Namespace: backup.Namespace}
+	} else {
+		return nil, nil
+	}
+	if err := r.Client.Get(ctx, key, reachable); err != nil {
+		if errors.IsNotFound(err) {
+			r.Log.Info("Leaving the backup set behind, it cannot be reached", "WordpressBackup.Namespace", backup.Namespace,
+				"WordpressBackup.Name", backup.Name, "Location", backup.Status.Location)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	job, err := r.jobForBackupCleanup(wordpress, backup)
+	if err != nil {
+		return nil, err
+	}
+	cleanupJob := &batchv1.Job{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, cleanupJob)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating backup cleanup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err := r.Client.Create(ctx, job); err != nil {
+			return nil, err
+		}
+		return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if cleanupJob.Status.Succeeded > 0 {
+		r.Log.Info("Removed backup set", "WordpressBackup.Namespace", backup.Namespace,
+			"WordpressBackup.Name", backup.Name, "Location", backup.Status.Location)
+		return nil, nil
+	}
+	if message, failed := jobFailure(cleanupJob); failed {
+		r.Log.Error(fmt.Errorf("%s", message), "Failed to remove backup set, leaving it behind",
+			"WordpressBackup.Namespace", backup.Namespace, "WordpressBackup.Name", backup.Name, "Location", backup.Status.Location)
+		return nil, nil
+	}
+	return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+}
+
+// jobForBackupCleanup creates the Job removing the backup set of a backup.
+// cr is nil when the instance is gone, which only happens for S3.
+func (r *WordpressBackupReconciler) jobForBackupCleanup(cr *v1.Wordpress, backup *v1.WordpressBackup) (*batchv1.Job, error) {
+	labels := map[string]string{
+		"app": backup.Spec.WordpressRef.Name,
+	}
+
+	container := corev1.Container{
+		Name:    "cleanup",
+		Command: []string{"bash", "-c", backupCleanupScript},
+		Env: []corev1.EnvVar{
+			{
+				Name:  "BACKUP_NAME",
+				Value: backup.Name,
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "backup-storage",
+				MountPath: "/backup",
+			},
+		},
+	}
+	if s3 := backup.Status.S3; s3 != nil {
+		container.Image = s3Image(s3)
+		container.Env = append(container.Env, s3Env(s3)...)
+	} else {
+		container.Image = backupImage(cr)
+	}
+
+	backoffLimit := int32(3)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupCleanupJobName(backup),
+			Namespace: backup.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Containers:    []corev1.Container{container},
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Volumes:       []corev1.Volume{backupStorageVolume(backup)},
+				},
+			},
+		},
+	}
+
+	if cr != nil {
+		applyImagePolicy(cr, &job.Spec.Template.Spec)
+		applyJobOverrides(cr, &job.Spec.Template.Spec)
+	}
+	applyJobSecurityContext(cr, &job.Spec.Template.Spec)
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// jobFailure reports whether a Job gave up, and why
+func jobFailure(job *batchv1.Job) (string, bool) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			return cond.Message, true
+		}
+	}
+	return "", false
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WordpressBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.WordpressBackup{}).
+		Owns(&batchv1.Job{}). // Watches for the backup and cleanup Jobs
+		Complete(r)
+}
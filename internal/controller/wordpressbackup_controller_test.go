@@ -0,0 +1,199 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wordpressv1alpha1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+)
+
+var _ = Describe("WordpressBackup Controller", func() {
+	Context("When reconciling a resource", func() {
+		const wordpressName = "backup-source"
+		const backupName = "backup-test"
+		includeContent := true
+
+		ctx := context.Background()
+
+		backupNamespacedName := types.NamespacedName{
+			Name:      backupName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating the Wordpress instance and a backup of it")
+			wordpress := &wordpressv1alpha1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      wordpressName,
+					Namespace: "default",
+				},
+			}
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+
+			backup := &wordpressv1alpha1.WordpressBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupName,
+					Namespace: "default",
+				},
+				Spec: wordpressv1alpha1.WordpressBackupSpec{
+					WordpressRef:   corev1.LocalObjectReference{Name: wordpressName},
+					IncludeContent: &includeContent,
+				},
+			}
+			Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Cleanup the backup and the instance")
+			// envtest runs no garbage collector, remove the Job by hand
+			job := &batchv1.Job{}
+			jobName := types.NamespacedName{Name: backupName + "-backup", Namespace: "default"}
+			if err := k8sClient.Get(ctx, jobName, job); err == nil {
+				Expect(k8sClient.Delete(ctx, job)).To(Succeed())
+			}
+
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+			controllerutil.RemoveFinalizer(backup, backupFinalizer)
+			Expect(k8sClient.Update(ctx, backup)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, backup)).To(Succeed())
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, wordpress)).To(Succeed())
+		})
+
+		reconcileBackup := func() {
+			controllerReconciler := &WordpressBackupReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: backupNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should start a Job dumping the database and the content", func() {
+			By("Reconciling the created backup")
+			reconcileBackup()
+			reconcileBackup()
+
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+			Expect(backup.Finalizers).To(ContainElement(backupFinalizer))
+			Expect(backup.Status.Phase).To(Equal(wordpressv1alpha1.BackupPending))
+			Expect(backup.Status.ClaimName).To(Equal(wordpressName + "-backup-pv-claim"))
+			Expect(backup.Status.Location).To(Equal("pvc/" + wordpressName + "-backup-pv-claim/" + backupName + "/"))
+
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: backupName + "-backup", Namespace: "default"}, job)).To(Succeed())
+			Expect(metav1.IsControlledBy(job, backup)).To(BeTrue())
+			spec := job.Spec.Template.Spec
+			Expect(spec.InitContainers).To(HaveLen(2))
+			Expect(spec.InitContainers[0].Name).To(Equal("mysql-dump"))
+			// Replicas may lag behind, the dump is taken from the primary
+			Expect(spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "MYSQL_HOST",
+				Value: wordpressName + "-mysql-0." + wordpressName + "-mysql",
+			}))
+			Expect(spec.InitContainers[1].Name).To(Equal("content-archive"))
+			Expect(spec.Containers[0].Name).To(Equal("finalize"))
+		})
+
+		It("should keep the content backup next to WordPress when Jobs are placed", func() {
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			wordpress.Spec.Pods = &wordpressv1alpha1.PodsSpec{
+				Jobs: &wordpressv1alpha1.PodTemplateOverrides{
+					NodeSelector: map[string]string{"disktype": "ssd"},
+				},
+			}
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+
+			controllerReconciler := &WordpressReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			job, err := controllerReconciler.jobForBackup(wordpress, backup)
+			Expect(err).NotTo(HaveOccurred())
+			spec := job.Spec.Template.Spec
+			Expect(spec.NodeSelector).To(HaveKeyWithValue("disktype", "ssd"))
+			// The content volume may only attach to the node of WordPress
+			Expect(spec.Affinity).NotTo(BeNil())
+			Expect(spec.Affinity.PodAffinity).NotTo(BeNil())
+			term := spec.Affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].PodAffinityTerm
+			Expect(term.LabelSelector.MatchLabels).To(HaveKeyWithValue("tier", "frontend"))
+		})
+
+		It("should record a failed Job", func() {
+			reconcileBackup()
+			reconcileBackup()
+
+			By("Failing the backup Job")
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: backupName + "-backup", Namespace: "default"}, job)).To(Succeed())
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+				Type:    batchv1.JobFailed,
+				Status:  corev1.ConditionTrue,
+				Reason:  "BackoffLimitExceeded",
+				Message: "Job has reached the specified backoff limit",
+			})
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+			reconcileBackup()
+
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+			Expect(backup.Status.Phase).To(Equal(wordpressv1alpha1.BackupFailed))
+			Expect(backup.Status.Message).To(ContainSubstring("backoff limit"))
+			Expect(backup.Status.CompletionTime).NotTo(BeNil())
+		})
+
+		It("should fail a VolumeSnapshot backup when the cluster has no VolumeSnapshots", func() {
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+			backup.Spec.Method = wordpressv1alpha1.BackupMethodVolumeSnapshot
+			Expect(k8sClient.Update(ctx, backup)).To(Succeed())
+
+			reconcileBackup()
+			reconcileBackup()
+
+			Expect(k8sClient.Get(ctx, backupNamespacedName, backup)).To(Succeed())
+			Expect(backup.Status.Method).To(Equal(wordpressv1alpha1.BackupMethodVolumeSnapshot))
+			Expect(backup.Status.Phase).To(Equal(wordpressv1alpha1.BackupFailed))
+			Expect(backup.Status.Message).To(ContainSubstring("VolumeSnapshots"))
+			Expect(backup.Status.VolumeSnapshots).To(HaveLen(2))
+
+			job := &batchv1.Job{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: backupName + "-lock", Namespace: "default"}, job)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,364 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// restoreFinalizer starts WordPress again when a restore is deleted halfway
+const restoreFinalizer = "wordpress.gopkg.blogpost.com/restore"
+
+// WordpressRestoreReconciler reconciles a WordpressRestore object
+type WordpressRestoreReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressrestores,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressrestores/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=wordpress.gopkg.blogpost.com,resources=wordpressrestores/finalizers,verbs=update
+
+// Reconcile walks a restore through its stages: stop WordPress, load the
+// database dump, unpack the content and start WordPress again. Each stage is
+// reported as a condition. Once a restore has succeeded or failed it is left
+// alone.
+func (r *WordpressRestoreReconciler) Reconcile(ctx context.Context, request ctrl.Request) (result ctrl.Result, err error) {
+	_ = r.Log.WithValues("wordpressrestore", request.NamespacedName)
+
+	restore := &v1.WordpressRestore{}
+	if err := r.Client.Get(ctx, request.NamespacedName, restore); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	var wordpress *v1.Wordpress
+	found := &v1.Wordpress{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: restore.Spec.WordpressRef.Name, Namespace: restore.Namespace}, found)
+	if err == nil {
+		wordpress = found
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+
+	if !restore.DeletionTimestamp.IsZero() {
+		if !controllerutil.ContainsFinalizer(restore, restoreFinalizer) {
+			return ctrl.Result{}, nil
+		}
+		if wordpress != nil {
+			if err := r.releaseInstance(ctx, restore, wordpress); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		controllerutil.RemoveFinalizer(restore, restoreFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, restore)
+	}
+
+	if controllerutil.AddFinalizer(restore, restoreFinalizer) {
+		return ctrl.Result{}, r.Client.Update(ctx, restore)
+	}
+	if restore.Status.Phase == v1.RestoreSucceeded || restore.Status.Phase == v1.RestoreFailed {
+		return ctrl.Result{}, nil
+	}
+
+	// Write whatever was observed to the status subresource, however this
+	// reconcile ends
+	originalStatus := restore.Status.DeepCopy()
+	defer func() {
+		if equality.Semantic.DeepEqual(originalStatus, &restore.Status) {
+			return
+		}
+		if statusErr := r.Client.Status().Update(ctx, restore); statusErr != nil && err == nil {
+			err = statusErr
+		}
+	}()
+
+	if wordpress == nil {
+		return ctrl.Result{}, r.failRestore(ctx, restore, nil,
+			fmt.Sprintf("Wordpress %q not found", restore.Spec.WordpressRef.Name))
+	}
+
+	src, message, failed, err := r.restoreSource(ctx, restore, wordpress)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if failed {
+		return ctrl.Result{}, r.failRestore(ctx, restore, wordpress, message)
+	}
+	if src == nil {
+		restore.Status.Phase = v1.RestorePending
+		restore.Status.Message = message
+		return ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	}
+	restore.Status.Location = src.location()
+
+	// Stage 1: Stop WordPress
+	if result, err := r.scaleDown(ctx, restore, wordpress); result != nil || err != nil {
+		return resultOrEmpty(result), err
+	}
+
+	// Stage 2: Load the database dump
+	if result, err := r.runRestoreJob(ctx, restore, wordpress, src, restoreDatabaseStage); result != nil || err != nil {
+		return resultOrEmpty(result), err
+	}
+
+	// Stage 3: Unpack the content, when there may be any
+	if src.includesContent && hasContentVolume(wordpress) {
+		if result, err := r.runRestoreJob(ctx, restore, wordpress, src, restoreContentStage); result != nil || err != nil {
+			return resultOrEmpty(result), err
+		}
+	} else if meta.FindStatusCondition(restore.Status.Conditions, v1.RestoreConditionContentRestored) == nil {
+		if src.includesContent {
+			setRestoreCondition(restore, v1.RestoreConditionContentRestored, metav1.ConditionFalse, "NoContentVolume",
+				"The instance keeps no content on a volume, the files in the backup are not restored")
+		} else {
+			setRestoreCondition(restore, v1.RestoreConditionContentRestored, metav1.ConditionFalse, "NotInBackup",
+				"The backup holds no content, the current files are kept")
+		}
+	}
+
+	// Stage 4: Start WordPress again
+	if result, err := r.scaleUp(ctx, restore, wordpress); result != nil || err != nil {
+		return resultOrEmpty(result), err
+	}
+
+	r.Log.Info("Restore succeeded", "WordpressRestore.Namespace", restore.Namespace, "WordpressRestore.Name", restore.Name)
+	now := metav1.Now()
+	restore.Status.Phase = v1.RestoreSucceeded
+	restore.Status.CompletionTime = &now
+	restore.Status.Message = fmt.Sprintf("Restored %s from %s", wordpress.Name, restore.Status.Location)
+	return ctrl.Result{}, nil
+}
+
+// restoreSource resolves the backup set of a restore. When it cannot be
+// restored from, the source is nil and the message tells why. failed is set
+// when it never will be.
+func (r *WordpressRestoreReconciler) restoreSource(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress) (src *restoreSource, message string, failed bool, err error) {
+	if loc := restore.Spec.Location; loc != nil {
+		src = &restoreSource{
+			name:            loc.Name,
+			claimName:       loc.ClaimName,
+			includesContent: true,
+		}
+		if loc.S3 != nil {
+			src.s3 = loc.S3.DeepCopy()
+			src.s3.Prefix = s3Prefix(cr, loc.S3)
+		}
+		return src, "", false, nil
+	}
+
+	backup := &v1.WordpressBackup{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: restore.Spec.BackupRef.Name, Namespace: restore.Namespace}, backup)
+	if errors.IsNotFound(err) {
+		return nil, fmt.Sprintf("WordpressBackup %q not found", restore.Spec.BackupRef.Name), true, nil
+	} else if err != nil {
+		return nil, "", false, err
+	}
+
+	if isSnapshotBackup(backup) {
+		return nil, fmt.Sprintf("WordpressBackup %q holds VolumeSnapshots, clone a new instance from it with spec.dataSource instead",
+			backup.Name), true, nil
+	}
+
+	switch backup.Status.Phase {
+	case v1.BackupSucceeded:
+		return &restoreSource{
+			name:            backup.Name,
+			claimName:       backup.Status.ClaimName,
+			s3:              backup.Status.S3,
+			includesContent: backupIncludesContent(backup),
+		}, "", false, nil
+	case v1.BackupFailed:
+		return nil, fmt.Sprintf("WordpressBackup %q failed", backup.Name), true, nil
+	default:
+		return nil, fmt.Sprintf("Waiting for WordpressBackup %q to complete", backup.Name), false, nil
+	}
+}
+
+// scaleDown marks the instance as being restored, which has the Wordpress
+// controller scale WordPress to zero, and waits for its pods to be gone. Only
+// one restore of an instance runs at a time.
+func (r *WordpressRestoreReconciler) scaleDown(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress) (*ctrl.Result, error) {
+	if meta.IsStatusConditionTrue(restore.Status.Conditions, v1.RestoreConditionScaledDown) {
+		return nil, nil
+	}
+
+	switch holder := cr.Annotations[v1.RestoreInProgressAnnotation]; holder {
+	case restore.Name:
+	case "":
+		if cr.Annotations == nil {
+			cr.Annotations = map[string]string{}
+		}
+		cr.Annotations[v1.RestoreInProgressAnnotation] = restore.Name
+		r.Log.Info("Stopping WordPress for restore", "Wordpress.Namespace", cr.Namespace, "Wordpress.Name", cr.Name)
+		if err := r.Client.Update(ctx, cr); err != nil {
+			return nil, err
+		}
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+	default:
+		restore.Status.Phase = v1.RestorePending
+		restore.Status.Message = fmt.Sprintf("Waiting for restore %s to finish", holder)
+		return &ctrl.Result{RequeueAfter: time.Second * 30}, nil
+	}
+
+	restore.Status.Phase = v1.RestoreScalingDown
+	restore.Status.Message = "Waiting for WordPress to stop"
+	dep := &appsv1.Deployment{}
+	err := r.Client.Get(ctx, types.NamespacedName{Name: wordpressName(cr), Namespace: cr.Namespace}, dep)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && (dep.Spec.Replicas == nil || *dep.Spec.Replicas > 0 || dep.Status.Replicas > 0) {
+		setRestoreCondition(restore, v1.RestoreConditionScaledDown, metav1.ConditionFalse, "PodsRunning",
+			fmt.Sprintf("%d WordPress pods still running", dep.Status.Replicas))
+		return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
+
+	setRestoreCondition(restore, v1.RestoreConditionScaledDown, metav1.ConditionTrue, "ScaledDown", "WordPress is stopped")
+	return nil, nil
+}
+
+// runRestoreJob runs the Job of a restore stage to completion
+func (r *WordpressRestoreReconciler) runRestoreJob(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress, src *restoreSource, stage string) (*ctrl.Result, error) {
+	phase, condition := v1.RestoringDatabase, v1.RestoreConditionDatabaseRestored
+	if stage == restoreContentStage {
+		phase, condition = v1.RestoringContent, v1.RestoreConditionContentRestored
+	}
+	if meta.IsStatusConditionTrue(restore.Status.Conditions, condition) {
+		return nil, nil
+	}
+	restore.Status.Phase = phase
+
+	job, err := r.wordpressReconciler().jobForRestore(cr, restore, src, stage)
+	if err != nil {
+		return nil, err
+	}
+	found := &batchv1.Job{}
+	err = r.Client.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found)
+	if err != nil && errors.IsNotFound(err) {
+		r.Log.Info("Creating restore Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		if err := r.Client.Create(ctx, job); err != nil {
+			r.Log.Error(err, "Failed to create restore Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			return nil, err
+		}
+		setRestoreCondition(restore, condition, metav1.ConditionFalse, "JobRunning", fmt.Sprintf("Job %s is running", job.Name))
+		restore.Status.Message = fmt.Sprintf("Job %s is running", job.Name)
+		return &ctrl.Result{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if found.Status.Succeeded > 0 {
+		setRestoreCondition(restore, condition, metav1.ConditionTrue, "JobSucceeded", fmt.Sprintf("Job %s succeeded", found.Name))
+		return nil, nil
+	}
+	if message, failed := jobFailure(found); failed {
+		setRestoreCondition(restore, condition, metav1.ConditionFalse, "JobFailed", message)
+		return &ctrl.Result{}, r.failRestore(ctx, restore, cr, fmt.Sprintf("Job %s failed: %s", found.Name, message))
+	}
+	return &ctrl.Result{}, nil
+}
+
+// scaleUp releases the instance, which has the Wordpress controller start
+// WordPress again, and waits for it to serve
+func (r *WordpressRestoreReconciler) scaleUp(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress) (*ctrl.Result, error) {
+	if err := r.releaseInstance(ctx, restore, cr); err != nil {
+		return nil, err
+	}
+	restore.Status.Phase = v1.RestoreScalingUp
+	restore.Status.Message = "Waiting for WordPress to start"
+
+	// The HorizontalPodAutoscaler owns the replica count of an autoscaled
+	// instance, which then serves once its minimum is ready
+	replicas := wordpressReplicas(cr)
+	dep := &appsv1.Deployment{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: wordpressName(cr), Namespace: cr.Namespace}, dep); err != nil {
+		return nil, err
+	}
+	scaled := dep.Spec.Replicas != nil && (isAutoscaled(cr) || *dep.Spec.Replicas == replicas)
+	if !scaled || dep.Status.ReadyReplicas < replicas {
+		setRestoreCondition(restore, v1.RestoreConditionScaledUp, metav1.ConditionFalse, "PodsNotReady",
+			fmt.Sprintf("%d of %d WordPress pods ready", dep.Status.ReadyReplicas, replicas))
+		return &ctrl.Result{RequeueAfter: time.Second * 5}, nil
+	}
+
+	setRestoreCondition(restore, v1.RestoreConditionScaledUp, metav1.ConditionTrue, "ScaledUp", "WordPress is serving")
+	return nil, nil
+}
+
+// failRestore records the failure of a restore and starts WordPress again.
+// The database may have been partly restored by then, which the message
+// points out.
+func (r *WordpressRestoreReconciler) failRestore(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress, message string) error {
+	r.Log.Info("Restore failed: "+message, "WordpressRestore.Namespace", restore.Namespace, "WordpressRestore.Name", restore.Name)
+	if meta.IsStatusConditionTrue(restore.Status.Conditions, v1.RestoreConditionScaledDown) &&
+		!meta.IsStatusConditionTrue(restore.Status.Conditions, v1.RestoreConditionContentRestored) {
+		message += ", the site may be partly restored"
+	}
+	now := metav1.Now()
+	restore.Status.Phase = v1.RestoreFailed
+	restore.Status.CompletionTime = &now
+	restore.Status.Message = message
+	if cr == nil {
+		return nil
+	}
+	return r.releaseInstance(ctx, restore, cr)
+}
+
+// releaseInstance removes the mark of the restore from the instance, unless
+// another restore holds it
+func (r *WordpressRestoreReconciler) releaseInstance(ctx context.Context, restore *v1.WordpressRestore, cr *v1.Wordpress) error {
+	if cr.Annotations[v1.RestoreInProgressAnnotation] != restore.Name {
+		return nil
+	}
+	delete(cr.Annotations, v1.RestoreInProgressAnnotation)
+	r.Log.Info("Starting WordPress after restore", "Wordpress.Namespace", cr.Namespace, "Wordpress.Name", cr.Name)
+	return r.Client.Update(ctx, cr)
+}
+
+// wordpressReconciler shares the object builders of the Wordpress controller
+func (r *WordpressRestoreReconciler) wordpressReconciler() *WordpressReconciler {
+	return &WordpressReconciler{
+		Client: r.Client,
+		Log:    r.Log,
+		Scheme: r.Scheme,
+	}
+}
+
+// setRestoreCondition records a condition against the current generation of
+// the restore
+func setRestoreCondition(restore *v1.WordpressRestore, condType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{
+		Type:               condType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: restore.Generation,
+	})
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WordpressRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1.WordpressRestore{}).
+		Owns(&batchv1.Job{}). // Watches for the restore Jobs
+		Complete(r)
+}
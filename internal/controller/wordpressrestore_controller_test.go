@@ -0,0 +1,211 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wordpressv1alpha1 "github.com/vyas-git/wordpress-operator/api/v1alpha1"
+)
+
+var _ = Describe("WordpressRestore Controller", func() {
+	Context("When reconciling a resource", func() {
+		const wordpressName = "restore-target"
+		const backupName = "restore-source"
+		const restoreName = "restore-test"
+
+		ctx := context.Background()
+
+		restoreNamespacedName := types.NamespacedName{
+			Name:      restoreName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating the Wordpress instance and a succeeded backup of it")
+			wordpress := &wordpressv1alpha1.Wordpress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      wordpressName,
+					Namespace: "default",
+				},
+			}
+			Expect(k8sClient.Create(ctx, wordpress)).To(Succeed())
+
+			backup := &wordpressv1alpha1.WordpressBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      backupName,
+					Namespace: "default",
+				},
+				Spec: wordpressv1alpha1.WordpressBackupSpec{
+					WordpressRef: corev1.LocalObjectReference{Name: wordpressName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+			backup.Status.Phase = wordpressv1alpha1.BackupSucceeded
+			backup.Status.ClaimName = wordpressName + "-backup-pv-claim"
+			Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Cleanup the restore, the backup and the instance")
+			// envtest runs no garbage collector, remove the Job by hand
+			job := &batchv1.Job{}
+			jobName := types.NamespacedName{Name: restoreName + "-database", Namespace: "default"}
+			if err := k8sClient.Get(ctx, jobName, job); err == nil {
+				Expect(k8sClient.Delete(ctx, job)).To(Succeed())
+			}
+
+			restore := &wordpressv1alpha1.WordpressRestore{}
+			Expect(k8sClient.Get(ctx, restoreNamespacedName, restore)).To(Succeed())
+			controllerutil.RemoveFinalizer(restore, restoreFinalizer)
+			Expect(k8sClient.Update(ctx, restore)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, restore)).To(Succeed())
+
+			backup := &wordpressv1alpha1.WordpressBackup{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: backupName, Namespace: "default"}, backup)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, backup)).To(Succeed())
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, wordpress)).To(Succeed())
+		})
+
+		createRestore := func(backup string) {
+			restore := &wordpressv1alpha1.WordpressRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      restoreName,
+					Namespace: "default",
+				},
+				Spec: wordpressv1alpha1.WordpressRestoreSpec{
+					WordpressRef: corev1.LocalObjectReference{Name: wordpressName},
+					BackupRef:    &corev1.LocalObjectReference{Name: backup},
+				},
+			}
+			Expect(k8sClient.Create(ctx, restore)).To(Succeed())
+		}
+
+		reconcileRestore := func() {
+			controllerReconciler := &WordpressRestoreReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: restoreNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should stop WordPress and load the database dump", func() {
+			createRestore(backupName)
+
+			By("Reconciling the created restore")
+			reconcileRestore()
+			reconcileRestore()
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			Expect(wordpress.Annotations).To(HaveKeyWithValue(wordpressv1alpha1.RestoreInProgressAnnotation, restoreName))
+
+			restore := &wordpressv1alpha1.WordpressRestore{}
+			Expect(k8sClient.Get(ctx, restoreNamespacedName, restore)).To(Succeed())
+			Expect(restore.Status.Phase).To(Equal(wordpressv1alpha1.RestoringDatabase))
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, wordpressv1alpha1.RestoreConditionScaledDown)).To(BeTrue())
+
+			job := &batchv1.Job{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: restoreName + "-database", Namespace: "default"}, job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Name).To(Equal("restore-database"))
+			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(wordpressName + "-backup-pv-claim"))
+		})
+
+		It("should finish scaling up an autoscaled instance once its minimum is ready", func() {
+			createRestore(backupName)
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			minReplicas := int32(2)
+			wordpress.Spec.Autoscaling = &wordpressv1alpha1.AutoscalingSpec{
+				MinReplicas: &minReplicas,
+				MaxReplicas: 5,
+			}
+			Expect(k8sClient.Update(ctx, wordpress)).To(Succeed())
+
+			By("having the HorizontalPodAutoscaler scale WordPress past its minimum")
+			replicas := int32(4)
+			labels := map[string]string{"app": wordpressName, "tier": "frontend"}
+			dep := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      wordpressName + "-wordpress",
+					Namespace: "default",
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "wordpress", Image: "wordpress"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, dep)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, dep)).To(Succeed())
+			}()
+			dep.Status.Replicas = 4
+			dep.Status.ReadyReplicas = 2
+			Expect(k8sClient.Status().Update(ctx, dep)).To(Succeed())
+
+			controllerReconciler := &WordpressRestoreReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			restore := &wordpressv1alpha1.WordpressRestore{}
+			Expect(k8sClient.Get(ctx, restoreNamespacedName, restore)).To(Succeed())
+			result, err := controllerReconciler.scaleUp(ctx, restore, wordpress)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(BeNil())
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, wordpressv1alpha1.RestoreConditionScaledUp)).To(BeTrue())
+		})
+
+		It("should fail without touching WordPress when the backup is missing", func() {
+			createRestore("missing-backup")
+
+			reconcileRestore()
+			reconcileRestore()
+
+			restore := &wordpressv1alpha1.WordpressRestore{}
+			Expect(k8sClient.Get(ctx, restoreNamespacedName, restore)).To(Succeed())
+			Expect(restore.Status.Phase).To(Equal(wordpressv1alpha1.RestoreFailed))
+
+			wordpress := &wordpressv1alpha1.Wordpress{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: wordpressName, Namespace: "default"}, wordpress)).To(Succeed())
+			Expect(wordpress.Annotations).NotTo(HaveKey(wordpressv1alpha1.RestoreInProgressAnnotation))
+		})
+	})
+})
@@ -117,5 +117,44 @@ var _ = Describe("controller", Ordered, func() {
 			EventuallyWithOffset(1, verifyControllerUp, time.Minute, time.Second).Should(Succeed())
 
 		})
+
+		It("should upload backups to a local S3 stand-in", func() {
+			const s3Namespace = "wordpress-s3-e2e"
+
+			By("deploying MinIO and a Wordpress instance backing up to it")
+			cmd := exec.Command("kubectl", "create", "ns", s3Namespace)
+			_, err := utils.Run(cmd)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				_, _ = utils.Run(exec.Command("kubectl", "delete", "ns", s3Namespace))
+			})
+			cmd = exec.Command("kubectl", "apply", "-k", "config/samples/s3", "-n", s3Namespace)
+			_, err = utils.Run(cmd)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+			By("waiting for a scheduled backup to succeed")
+			verifyBackupSucceeded := func() error {
+				cmd := exec.Command("kubectl", "get", "wordpress", "wordpress-s3",
+					"-o", "jsonpath={.status.backup.lastSuccessfulTime}", "-n", s3Namespace)
+				output, err := utils.Run(cmd)
+				if err != nil {
+					return err
+				}
+				if len(output) == 0 {
+					return fmt.Errorf("no backup has succeeded yet")
+				}
+				return nil
+			}
+			EventuallyWithOffset(1, verifyBackupSucceeded, 15*time.Minute, 10*time.Second).Should(Succeed())
+
+			By("listing the uploaded backups")
+			cmd = exec.Command("kubectl", "run", "mc", "-n", s3Namespace, "--rm", "-i", "--restart=Never",
+				"--image=minio/mc:RELEASE.2024-01-16T16-06-34Z", "--command", "--", "sh", "-c",
+				"mc alias set local http://minio:9000 minioadmin minioadmin >/dev/null && "+
+					"mc ls --recursive local/wordpress-backups/"+s3Namespace+"/wordpress-s3/")
+			output, err := utils.Run(cmd)
+			ExpectWithOffset(1, err).NotTo(HaveOccurred())
+			ExpectWithOffset(1, string(output)).To(ContainSubstring("database.sql.gz"))
+		})
 	})
 })